@@ -13,10 +13,14 @@ import (
 // It also returns when the context is cancelled.
 //
 // This function drops all received messages that is not an ack,
-// or ack messages that the sequence and source don't match.
+// or ack messages that the sequence and source don't match,
+// and reports them to the Tracer from the context.
+// StateUnhandled replies to the sequences are returned as the Cause.
 // Therefore, there shouldn't be more than one WaitForAcks functions running for
 // the same connection at the same time,
 // and this function should only be used when no other responses are expected.
+// If you need to wait for acks while other calls are running concurrently,
+// use a separated connection dialed from a shared Client for each call.
 //
 // If this function returns an error,
 // the error would be of type *WaitForAcksError.
@@ -25,6 +29,47 @@ func WaitForAcks(
 	conn net.Conn,
 	source uint32,
 	sequences ...uint8,
+) error {
+	groups := make(map[uint8]int, len(sequences))
+	for i, seq := range sequences {
+		groups[seq] = i
+	}
+	return waitForAcks(ctx, conn, source, sequences, groups, nil)
+}
+
+// WaitForAnyAck is similar to WaitForAcks,
+// but it returns nil error as soon as the ack for any of the sequences is
+// received.
+//
+// It's useful when the same message was sent multiple times with different
+// sequences (e.g. retransmissions),
+// and an ack to any of them is good enough.
+func WaitForAnyAck(
+	ctx context.Context,
+	conn net.Conn,
+	source uint32,
+	sequences ...uint8,
+) error {
+	groups := make(map[uint8]int, len(sequences))
+	for _, seq := range sequences {
+		groups[seq] = 0
+	}
+	return waitForAcks(ctx, conn, source, sequences, groups, nil)
+}
+
+// waitForAcks waits until the ack for any sequence of every group is
+// received.
+//
+// groups maps the sequences to wait for to their groups,
+// e.g. the retransmissions of the same message are in the same group.
+// sequences are only used as the Total of the returned error.
+func waitForAcks(
+	ctx context.Context,
+	conn net.Conn,
+	source uint32,
+	sequences []uint8,
+	groups map[uint8]int,
+	onAck func(seq uint8),
 ) error {
 	e := &WaitForAcksError{
 		Received: make([]uint8, 0, len(sequences)),
@@ -37,31 +82,52 @@ func WaitForAcks(
 		return e
 	}
 
-	if len(sequences) == 0 {
+	if len(groups) == 0 {
 		return nil
 	}
 
-	seqMap := make(map[uint8]bool)
-	for _, seq := range sequences {
-		seqMap[seq] = true
+	pending := make(map[int]bool)
+	for _, group := range groups {
+		pending[group] = true
 	}
 
+	tracer := TracerFromContext(ctx)
 	for {
 		resp, err := ReadNextResponse(ctx, conn)
+		if err != nil {
+			// Only fail on StateUnhandled or invalid replies to the sent
+			// messages.
+			resp = ErrorResponse(err)
+			if resp == nil {
+				e.Cause = err
+				return e
+			}
+		}
+		if resp.Source != source {
+			tracer.OnDrop(DropWrongSource, resp)
+			continue
+		}
+		if err == nil && resp.Message != Acknowledgement {
+			tracer.OnDrop(DropWrongType, resp)
+			continue
+		}
+		group, ok := groups[resp.Sequence]
+		if !ok || !pending[group] {
+			tracer.OnDrop(DropWrongSequence, resp)
+			continue
+		}
 		if err != nil {
 			e.Cause = err
 			return e
 		}
-		if resp.Source != source || resp.Message != Acknowledgement {
-			continue
+		if onAck != nil {
+			onAck(resp.Sequence)
 		}
-		if seqMap[resp.Sequence] {
-			e.Received = append(e.Received, resp.Sequence)
-			delete(seqMap, resp.Sequence)
-			if len(seqMap) == 0 {
-				// All ack received.
-				return nil
-			}
+		e.Received = append(e.Received, resp.Sequence)
+		delete(pending, group)
+		if len(pending) == 0 {
+			// Acks for all groups received.
+			return nil
 		}
 	}
 }
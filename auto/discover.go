@@ -0,0 +1,105 @@
+package auto
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"go.yhsif.com/lifxlan"
+)
+
+// DefaultDiscoverTimeout is the default value of DiscoverOptions.Timeout.
+const DefaultDiscoverTimeout = time.Second
+
+// DiscoverOptions defines the options used by Discover.
+type DiscoverOptions struct {
+	lifxlan.DiscoverOptions
+
+	// How long to discover the devices before wrapping them,
+	// the rest of the context is used to wrap the devices.
+	//
+	// Values <= 0 mean DefaultDiscoverTimeout.
+	Timeout time.Duration
+
+	// If non-empty, only the devices of these kinds are wrapped and returned,
+	// e.g. []Kind{KindTile} for matrix devices only.
+	Kinds []Kind
+}
+
+func (opts DiscoverOptions) timeout() time.Duration {
+	if opts.Timeout > 0 {
+		return opts.Timeout
+	}
+	return DefaultDiscoverTimeout
+}
+
+func (opts DiscoverOptions) wanted(kind Kind) bool {
+	if len(opts.Kinds) == 0 {
+		return true
+	}
+	for _, k := range opts.Kinds {
+		if k == kind {
+			return true
+		}
+	}
+	return false
+}
+
+// Discover discovers lifx products in the lan via lifxlan.DiscoverAll for
+// opts.Timeout,
+// and returns the devices found wrapped into their most specific device types
+// (see Wrap).
+//
+// Devices are detected and wrapped concurrently,
+// and filtered by opts.Kinds before wrapping,
+// so the devices of unwanted kinds don't cost any wrapping API calls.
+// Devices failed to be detected or wrapped (e.g. timed out) are skipped,
+// and logged to the Logger of the Config from the context.
+// The devices are in the order they are discovered.
+//
+// ctx should have a deadline longer than opts.Timeout to leave time for the
+// wrapping.
+// If ctx is done before all the devices are wrapped,
+// the devices wrapped so far are returned along with ctx.Err().
+func Discover(ctx context.Context, opts DiscoverOptions) ([]lifxlan.Device, error) {
+	discoverCtx, cancel := context.WithTimeout(ctx, opts.timeout())
+	defer cancel()
+	devices, err := lifxlan.DiscoverAll(discoverCtx, opts.DiscoverOptions)
+	if err != nil {
+		return nil, err
+	}
+
+	config := lifxlan.ConfigFromContext(ctx)
+	results := make([]lifxlan.Device, len(devices))
+	var wg sync.WaitGroup
+	for i, d := range devices {
+		wg.Add(1)
+		go func(i int, d lifxlan.Device) {
+			defer wg.Done()
+
+			kind, err := Detect(ctx, d, nil)
+			if err != nil {
+				config.Logf("lifxlan/auto.Discover: failed to detect %v: %v", d, err)
+				return
+			}
+			if !opts.wanted(kind) {
+				return
+			}
+			wrapped, err := WrapAs(ctx, d, kind)
+			if err != nil {
+				config.Logf("lifxlan/auto.Discover: failed to wrap %v as %v: %v", d, kind, err)
+				return
+			}
+			results[i] = wrapped
+		}(i, d)
+	}
+	wg.Wait()
+
+	var wrapped []lifxlan.Device
+	for _, d := range results {
+		if d != nil {
+			wrapped = append(wrapped, d)
+		}
+	}
+	return wrapped, ctx.Err()
+}
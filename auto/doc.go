@@ -0,0 +1,12 @@
+// Package auto wraps LIFX devices into the most specific device types
+// implemented by the subpackages of lifxlan,
+// based on their product features.
+//
+// Instead of guessing which Wrap function to call on a discovered device
+// (and waiting for StateUnhandled or timeouts on the wrong guesses),
+// it fetches the hardware version and firmware of the device,
+// consults lifxlan.ProductMap,
+// and only calls the Wrap function of the matching subpackage.
+//
+// Please refer to its parent package for more background/context.
+package auto // import "go.yhsif.com/lifxlan/auto"
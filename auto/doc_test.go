@@ -0,0 +1,28 @@
+package auto_test
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"go.yhsif.com/lifxlan/auto"
+	"go.yhsif.com/lifxlan/tile"
+)
+
+// This example demonstrates how to discover all the matrix devices as
+// tile.Device.
+func Example() {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	devices, err := auto.Discover(ctx, auto.DiscoverOptions{
+		Kinds: []auto.Kind{auto.KindTile},
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+	for _, d := range devices {
+		td := d.(tile.Device)
+		log.Printf("Found %v with %dx%d pixels", td, td.Width(), td.Height())
+	}
+}
@@ -0,0 +1,139 @@
+package auto
+
+import (
+	"context"
+	"fmt"
+	"net"
+
+	"go.yhsif.com/lifxlan"
+	"go.yhsif.com/lifxlan/light"
+	"go.yhsif.com/lifxlan/relay"
+	"go.yhsif.com/lifxlan/tile"
+)
+
+// Kind is the most specific device type a device can be wrapped into.
+type Kind int
+
+// Kind values.
+const (
+	// lifxlan.Device, for unknown products,
+	// or products without features implemented by the subpackages.
+	KindDevice Kind = iota
+
+	// light.Device.
+	KindLight
+
+	// relay.Device.
+	KindRelay
+
+	// tile.Device.
+	KindTile
+)
+
+func (k Kind) String() string {
+	switch k {
+	default:
+		return fmt.Sprintf("<UNKNOWN> (%d)", int(k))
+	case KindDevice:
+		return "device"
+	case KindLight:
+		return "light"
+	case KindRelay:
+		return "relay"
+	case KindTile:
+		return "tile"
+	}
+}
+
+// KindOf returns the Kind of the device with the features.
+//
+// Matrix devices are tiles,
+// devices with relays are relays,
+// and devices with color, multizone or temperature range are lights.
+func KindOf(features lifxlan.Features) Kind {
+	switch {
+	case features.Matrix.Get():
+		return KindTile
+	case features.Relays.Get():
+		return KindRelay
+	case features.Color.Get(),
+		features.Multizone.Get(),
+		features.TemperatureRange.Valid():
+		return KindLight
+	}
+	return KindDevice
+}
+
+// Detect returns the Kind of d.
+//
+// If d's HardwareVersion or Firmware is not cached,
+// they will be fetched from the device first.
+// The features are from lifxlan.ProductMap at the firmware version of d,
+// and KindDevice is returned for products not in lifxlan.ProductMap.
+//
+// If conn is nil,
+// a new connection will be made and guaranteed to be closed before returning.
+func Detect(ctx context.Context, d lifxlan.Device, conn net.Conn) (Kind, error) {
+	if d.HardwareVersion().String() == lifxlan.EmptyHardwareVersion ||
+		d.Firmware().String() == lifxlan.EmptyFirmware {
+		if conn == nil {
+			newConn, err := d.Dial()
+			if err != nil {
+				return KindDevice, err
+			}
+			defer newConn.Close()
+			conn = newConn
+		}
+
+		if d.HardwareVersion().String() == lifxlan.EmptyHardwareVersion {
+			if err := d.GetHardwareVersion(ctx, conn); err != nil {
+				return KindDevice, err
+			}
+		}
+		if d.Firmware().String() == lifxlan.EmptyFirmware {
+			if err := d.GetFirmware(ctx, conn); err != nil {
+				return KindDevice, err
+			}
+		}
+	}
+
+	product := d.HardwareVersion().Parse()
+	if product == nil {
+		return KindDevice, nil
+	}
+	return KindOf(product.FeaturesAt(*d.Firmware())), nil
+}
+
+// WrapAs wraps d into the device type of kind,
+// using the Wrap function of the matching subpackage.
+//
+// d is returned as-is for KindDevice.
+func WrapAs(ctx context.Context, d lifxlan.Device, kind Kind) (lifxlan.Device, error) {
+	switch kind {
+	default:
+		return nil, fmt.Errorf("lifxlan/auto.WrapAs: unknown kind %v", kind)
+	case KindDevice:
+		return d, nil
+	case KindLight:
+		return light.Wrap(ctx, d, false)
+	case KindRelay:
+		return relay.Wrap(ctx, d, false)
+	case KindTile:
+		return tile.Wrap(ctx, d, false)
+	}
+}
+
+// Wrap wraps d into its most specific device type,
+// which is light.Device, relay.Device, tile.Device,
+// or d itself for KindDevice (see Detect and KindOf).
+//
+// The returned device can be type asserted into the device type of the
+// returned Kind.
+func Wrap(ctx context.Context, d lifxlan.Device) (lifxlan.Device, Kind, error) {
+	kind, err := Detect(ctx, d, nil)
+	if err != nil {
+		return nil, kind, err
+	}
+	wrapped, err := WrapAs(ctx, d, kind)
+	return wrapped, kind, err
+}
@@ -0,0 +1,326 @@
+package auto_test
+
+import (
+	"context"
+	"net"
+	"strconv"
+	"testing"
+	"time"
+
+	"go.yhsif.com/lifxlan"
+	"go.yhsif.com/lifxlan/auto"
+	"go.yhsif.com/lifxlan/light"
+	"go.yhsif.com/lifxlan/mock"
+	"go.yhsif.com/lifxlan/relay"
+	"go.yhsif.com/lifxlan/tile"
+)
+
+// Product ids used by mockProductMap.
+const (
+	productLight = iota + 1
+	productRelay
+	productTile
+	productUpgrade
+	productUnknown
+)
+
+func mockProductMap(t *testing.T) {
+	t.Helper()
+
+	backupProductMap := lifxlan.ProductMap
+	t.Cleanup(func() {
+		lifxlan.ProductMap = backupProductMap
+	})
+
+	lifxlan.ProductMap = map[uint64]lifxlan.Product{
+		lifxlan.ProductMapKey(1, productLight): {
+			ProductName: "Light",
+			Features: lifxlan.Features{
+				Color: lifxlan.OptionalBoolPtr(true),
+			},
+		},
+		lifxlan.ProductMapKey(1, productRelay): {
+			ProductName: "Switch",
+			Features: lifxlan.Features{
+				Relays:  lifxlan.OptionalBoolPtr(true),
+				Buttons: lifxlan.OptionalBoolPtr(true),
+			},
+		},
+		lifxlan.ProductMapKey(1, productTile): {
+			ProductName: "Tile",
+			Features: lifxlan.Features{
+				Color:  lifxlan.OptionalBoolPtr(true),
+				Chain:  lifxlan.OptionalBoolPtr(true),
+				Matrix: lifxlan.OptionalBoolPtr(true),
+			},
+		},
+		lifxlan.ProductMapKey(1, productUpgrade): {
+			ProductName: "Upgrade",
+			Upgrades: lifxlan.Upgrades{
+				{
+					Major: 2,
+					Minor: 80,
+					Features: lifxlan.Features{
+						Multizone: lifxlan.OptionalBoolPtr(true),
+					},
+				},
+			},
+		},
+	}
+}
+
+// startService starts a mock service of the product,
+// failing the test on any wrapping API calls not for kind.
+func startService(t *testing.T, product uint32, major uint16, kind auto.Kind) (*mock.Service, lifxlan.Device) {
+	t.Helper()
+
+	service, device := mock.StartService(t)
+	service.RawStateVersionPayload = &lifxlan.RawStateVersionPayload{
+		Version: lifxlan.HardwareVersion{
+			VendorID:        1,
+			ProductID:       product,
+			HardwareVersion: 1,
+		},
+	}
+	service.RawStateHostFirmwarePayload = &lifxlan.RawStateHostFirmwarePayload{
+		VersionMajor: major,
+	}
+	service.RawStatePayload = new(light.RawStatePayload)
+	service.RawStateRPowerPayload = new(relay.RawStateRPowerPayload)
+	service.RawStateDeviceChainPayload = &tile.RawStateDeviceChainPayload{
+		TotalCount: 1,
+	}
+	service.RawStateDeviceChainPayload.TileDevices[0] = tile.RawTileDevice{
+		Width:  8,
+		Height: 8,
+		HardwareVersion: lifxlan.HardwareVersion{
+			VendorID:  1,
+			ProductID: productTile,
+		},
+	}
+
+	unexpected := func(s *mock.Service, conn net.PacketConn, addr net.Addr, orig *lifxlan.Response) {
+		t.Errorf("Unexpected %v for %v", orig.Message, kind)
+	}
+	if kind != auto.KindTile {
+		service.Handlers[tile.GetDeviceChain] = unexpected
+		if kind != auto.KindLight {
+			service.Handlers[light.Get] = unexpected
+		}
+	}
+	if kind != auto.KindRelay {
+		service.Handlers[relay.GetRPower] = unexpected
+	}
+	return service, device
+}
+
+func TestKindOf(t *testing.T) {
+	for _, c := range []struct {
+		label    string
+		features lifxlan.Features
+		expected auto.Kind
+	}{
+		{
+			label:    "empty",
+			expected: auto.KindDevice,
+		},
+		{
+			label: "color",
+			features: lifxlan.Features{
+				Color: lifxlan.OptionalBoolPtr(true),
+			},
+			expected: auto.KindLight,
+		},
+		{
+			label: "white",
+			features: lifxlan.Features{
+				Color:            lifxlan.OptionalBoolPtr(false),
+				TemperatureRange: lifxlan.TemperatureRange{2700, 2700},
+			},
+			expected: auto.KindLight,
+		},
+		{
+			label: "relays",
+			features: lifxlan.Features{
+				Relays: lifxlan.OptionalBoolPtr(true),
+			},
+			expected: auto.KindRelay,
+		},
+		{
+			label: "matrix",
+			features: lifxlan.Features{
+				Color:  lifxlan.OptionalBoolPtr(true),
+				Matrix: lifxlan.OptionalBoolPtr(true),
+			},
+			expected: auto.KindTile,
+		},
+	} {
+		t.Run(c.label, func(t *testing.T) {
+			if kind := auto.KindOf(c.features); kind != c.expected {
+				t.Errorf("Expected %v, got %v", c.expected, kind)
+			}
+		})
+	}
+}
+
+func TestWrap(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping test in short mode.")
+	}
+
+	const timeout = time.Millisecond * 200
+
+	mockProductMap(t)
+
+	for _, c := range []struct {
+		label    string
+		product  uint32
+		major    uint16
+		expected auto.Kind
+	}{
+		{
+			label:    "Light",
+			product:  productLight,
+			expected: auto.KindLight,
+		},
+		{
+			label:    "Relay",
+			product:  productRelay,
+			expected: auto.KindRelay,
+		},
+		{
+			label:    "Tile",
+			product:  productTile,
+			expected: auto.KindTile,
+		},
+		{
+			label:    "BeforeUpgrade",
+			product:  productUpgrade,
+			major:    2,
+			expected: auto.KindDevice,
+		},
+		{
+			label:    "AfterUpgrade",
+			product:  productUpgrade,
+			major:    3,
+			expected: auto.KindLight,
+		},
+		{
+			label:    "Unknown",
+			product:  productUnknown,
+			expected: auto.KindDevice,
+		},
+	} {
+		t.Run(c.label, func(t *testing.T) {
+			_, device := startService(t, c.product, c.major, c.expected)
+
+			ctx, cancel := context.WithTimeout(context.Background(), timeout)
+			defer cancel()
+			wrapped, kind, err := auto.Wrap(ctx, device)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if kind != c.expected {
+				t.Errorf("Expected kind %v, got %v", c.expected, kind)
+			}
+
+			var ok bool
+			switch c.expected {
+			case auto.KindDevice:
+				ok = wrapped == device
+			case auto.KindLight:
+				_, ok = wrapped.(light.Device)
+			case auto.KindRelay:
+				_, ok = wrapped.(relay.Device)
+			case auto.KindTile:
+				_, ok = wrapped.(tile.Device)
+			}
+			if !ok {
+				t.Errorf("Expected %v device, got %#v", c.expected, wrapped)
+			}
+		})
+	}
+}
+
+func TestDiscover(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping test in short mode.")
+	}
+
+	const timeout = time.Millisecond * 200
+
+	mockProductMap(t)
+
+	service, device := startService(t, productLight, 0, auto.KindLight)
+	_, portStr, err := net.SplitHostPort(device.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	port, err := strconv.Atoi(portStr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	service.Handlers[lifxlan.GetService] = func(
+		s *mock.Service,
+		conn net.PacketConn,
+		addr net.Addr,
+		orig *lifxlan.Response,
+	) {
+		payload, err := (&lifxlan.RawStateServicePayload{
+			Service: lifxlan.ServiceUDP,
+			Port:    uint32(port),
+		}).MarshalBinary()
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		s.Reply(conn, addr, orig, lifxlan.StateService, payload)
+	}
+
+	for _, c := range []struct {
+		label    string
+		kinds    []auto.Kind
+		expected int
+	}{
+		{
+			label:    "All",
+			expected: 1,
+		},
+		{
+			label:    "Light",
+			kinds:    []auto.Kind{auto.KindLight, auto.KindRelay},
+			expected: 1,
+		},
+		{
+			label:    "MatrixOnly",
+			kinds:    []auto.Kind{auto.KindTile},
+			expected: 0,
+		},
+	} {
+		t.Run(c.label, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), timeout*2)
+			defer cancel()
+			devices, err := auto.Discover(ctx, auto.DiscoverOptions{
+				DiscoverOptions: lifxlan.DiscoverOptions{
+					BroadcastHosts: []string{device.Addr().String()},
+				},
+				Timeout: timeout / 2,
+				Kinds:   c.kinds,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(devices) != c.expected {
+				t.Fatalf("Expected %d devices, got %v", c.expected, devices)
+			}
+			for _, d := range devices {
+				if _, ok := d.(light.Device); !ok {
+					t.Errorf("Expected light device, got %#v", d)
+				}
+				if d.Addr().String() != device.Addr().String() {
+					t.Errorf("Expected addr %v, got %v", device.Addr(), d.Addr())
+				}
+			}
+		})
+	}
+}
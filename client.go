@@ -0,0 +1,412 @@
+package lifxlan
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"sync"
+	"time"
+)
+
+// ClientConnQueueSize is the max number of received but not yet read messages
+// a connection dialed from Client can hold.
+//
+// When the queue is full, newly received messages for that connection will be
+// dropped, the same way an overflown UDP socket would drop them.
+const ClientConnQueueSize = 32
+
+// Client owns a single UDP socket and shares it among API calls to any number
+// of devices.
+//
+// Connections returned by Client.Dial and Client.DialDevice are light-weight
+// and can be passed as the conn arg into all the device APIs.
+// Every message written to such a connection registers a route by its
+// (target, source, sequence),
+// and the single read loop owned by the Client delivers every received
+// response to the connection with the matching route.
+// As a result, as long as concurrent API calls use different connections
+// dialed from the same Client, they won't eat each other's responses,
+// even when they are talking to the same device.
+//
+// Received messages without a matching route are delivered to the handlers
+// registered via Client.Listen, or dropped if there's none.
+type Client struct {
+	conn   net.PacketConn
+	config *Config
+
+	lock         sync.Mutex
+	routes       map[routeKey]*clientConn
+	listeners    map[int]ListenHandler
+	nextListener int
+	err          error
+
+	done chan struct{}
+	wg   sync.WaitGroup
+}
+
+type routeKey struct {
+	target   Target
+	source   uint32
+	sequence uint8
+}
+
+// ClientOption defines an option to be used with NewClient.
+type ClientOption func(c *Client)
+
+// WithClientConfig is a ClientOption to attach config to the Client.
+//
+// The Client uses config.ReadBufferSize to read from the socket,
+// and config.Logger to log the dropped messages.
+func WithClientConfig(config *Config) ClientOption {
+	return func(c *Client) {
+		if config != nil {
+			c.config = config
+		}
+	}
+}
+
+// NewClient creates a new Client on conn and starts its read loop.
+//
+// conn is usually created by net.ListenPacket("udp", ":0").
+// The Client takes over the ownership of conn:
+// conn will be closed when the Client is closed,
+// and the caller shall not read from conn directly.
+func NewClient(conn net.PacketConn, options ...ClientOption) *Client {
+	c := &Client{
+		conn:      conn,
+		config:    defaultConfig,
+		routes:    make(map[routeKey]*clientConn),
+		listeners: make(map[int]ListenHandler),
+		done:      make(chan struct{}),
+	}
+	for _, opt := range options {
+		opt(c)
+	}
+	c.wg.Add(1)
+	go c.readLoop()
+	return c
+}
+
+// LocalAddr returns the local address of the underlying socket.
+func (c *Client) LocalAddr() net.Addr {
+	return c.conn.LocalAddr()
+}
+
+// Close closes the underlying socket and stops the read loop.
+//
+// All connections dialed from this Client will stop working after Close.
+func (c *Client) Close() error {
+	err := c.conn.Close()
+	c.wg.Wait()
+	return err
+}
+
+// Dial returns a connection to address over the shared socket.
+//
+// network must be one of "udp", "udp4", and "udp6".
+//
+// The returned connection must be closed after use to release its routes.
+// Closing it does not close the shared socket.
+func (c *Client) Dial(network, address string) (net.Conn, error) {
+	switch network {
+	default:
+		return nil, fmt.Errorf(
+			"lifxlan.Client.Dial: unsupported network: %q",
+			network,
+		)
+	case "udp", "udp4", "udp6":
+	}
+	raddr, err := net.ResolveUDPAddr(network, address)
+	if err != nil {
+		return nil, err
+	}
+	select {
+	default:
+	case <-c.done:
+		return nil, c.readErr()
+	}
+	return &clientConn{
+		client: c,
+		raddr:  raddr,
+		queue:  make(chan []byte, ClientConnQueueSize),
+		closed: make(chan struct{}),
+		keys:   make(map[routeKey]bool),
+	}, nil
+}
+
+// DialDevice returns a connection to d over the shared socket.
+//
+// See Dial for more details.
+func (c *Client) DialDevice(d Device) (net.Conn, error) {
+	addr := d.Addr()
+	return c.Dial(addr.Network(), addr.String())
+}
+
+func (c *Client) readLoop() {
+	defer c.wg.Done()
+
+	buf := make([]byte, c.config.BufferSize())
+	for {
+		n, addr, err := c.conn.ReadFrom(buf)
+		if err != nil {
+			if CheckTimeoutError(err) {
+				continue
+			}
+			c.lock.Lock()
+			c.err = err
+			c.lock.Unlock()
+			close(c.done)
+			return
+		}
+		c.route(addr, buf[:n])
+	}
+}
+
+func (c *Client) readErr() error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.err
+}
+
+func (c *Client) route(addr net.Addr, msg []byte) {
+	header, ok := parseHeader(msg)
+	if !ok {
+		c.config.Logf(
+			"lifxlan.Client: dropping invalid message from %v: % x",
+			addr,
+			msg,
+		)
+		return
+	}
+
+	key := routeKey{
+		target:   header.Target,
+		source:   header.Source,
+		sequence: header.Sequence,
+	}
+	c.lock.Lock()
+	cc := c.routes[key]
+	if cc == nil {
+		// The original message could be sent to all devices.
+		key.target = AllDevices
+		cc = c.routes[key]
+	}
+	var listeners []ListenHandler
+	if cc == nil {
+		for _, handler := range c.listeners {
+			listeners = append(listeners, handler)
+		}
+	}
+	c.lock.Unlock()
+	if cc == nil {
+		if len(listeners) > 0 {
+			c.notify(addr, header, msg, listeners)
+			return
+		}
+		c.config.Logf(
+			"lifxlan.Client: dropping %v from %v without matching route: source=%d, sequence=%d",
+			header.Type,
+			addr,
+			header.Source,
+			header.Sequence,
+		)
+		return
+	}
+
+	if !cc.deliver(append([]byte(nil), msg...)) {
+		c.config.Logf(
+			"lifxlan.Client: dropping %v from %v as the queue is full",
+			header.Type,
+			addr,
+		)
+	}
+}
+
+// notify calls listeners with the Event parsed from msg.
+func (c *Client) notify(addr net.Addr, header RawHeader, msg []byte, listeners []ListenHandler) {
+	event, err := newEvent(addr, msg)
+	if err != nil {
+		c.config.Logf(
+			"lifxlan.Client: dropping %v from %v: %v",
+			header.Type,
+			addr,
+			err,
+		)
+		return
+	}
+	for _, handler := range listeners {
+		handler(event)
+	}
+}
+
+// Listen registers handler to be called with every message received by the
+// Client without a matching route,
+// e.g. the state messages sent by devices because another client changed them,
+// until the returned stop function is called.
+//
+// Devices only send such messages to the well-known port DefaultBroadcastPort,
+// so the Client's socket needs to be bound to DiscoverListenAddrFixed for
+// them.
+//
+// handler is called synchronously from the Client's read loop,
+// see ListenHandler for more details.
+// Multiple handlers can be registered on the same Client.
+func (c *Client) Listen(handler ListenHandler) (stop func()) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	id := c.nextListener
+	c.nextListener++
+	c.listeners[id] = handler
+	return func() {
+		c.lock.Lock()
+		defer c.lock.Unlock()
+		delete(c.listeners, id)
+	}
+}
+
+func (c *Client) register(cc *clientConn, key routeKey) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.routes[key] = cc
+}
+
+func (c *Client) unregister(cc *clientConn, keys map[routeKey]bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	for key := range keys {
+		// The same key could have been taken over by another connection.
+		if c.routes[key] == cc {
+			delete(c.routes, key)
+		}
+	}
+}
+
+// parseHeader parses the header of msg and validates it.
+func parseHeader(msg []byte) (header RawHeader, ok bool) {
+	if err := header.UnmarshalBinary(msg); err != nil {
+		return
+	}
+	if int(header.Size) != len(msg) || header.Validate() != nil {
+		return
+	}
+	return header, true
+}
+
+// clientConn is the net.Conn implementation returned by Client.Dial.
+type clientConn struct {
+	client *Client
+	raddr  net.Addr
+	queue  chan []byte
+
+	closed    chan struct{}
+	closeOnce sync.Once
+
+	lock         sync.Mutex
+	keys         map[routeKey]bool
+	readDeadline time.Time
+}
+
+var _ net.Conn = (*clientConn)(nil)
+
+// deliver returns false if msg is dropped because the queue is full.
+func (cc *clientConn) deliver(msg []byte) bool {
+	select {
+	default:
+		// Queue is full, drop it.
+		return false
+	case cc.queue <- msg:
+		return true
+	}
+}
+
+// Read reads the next message routed to this connection.
+//
+// The read deadline is only checked when Read is called,
+// changing the read deadline while a Read is blocking does not affect it.
+func (cc *clientConn) Read(b []byte) (int, error) {
+	cc.lock.Lock()
+	deadline := cc.readDeadline
+	cc.lock.Unlock()
+
+	var timeout <-chan time.Time
+	if !deadline.IsZero() {
+		d := time.Until(deadline)
+		if d <= 0 {
+			return 0, os.ErrDeadlineExceeded
+		}
+		timer := time.NewTimer(d)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+	select {
+	case msg := <-cc.queue:
+		return copy(b, msg), nil
+	case <-cc.closed:
+		return 0, ErrClientConnClosed
+	case <-cc.client.done:
+		return 0, cc.client.readErr()
+	case <-timeout:
+		return 0, os.ErrDeadlineExceeded
+	}
+}
+
+func (cc *clientConn) Write(b []byte) (int, error) {
+	select {
+	default:
+	case <-cc.closed:
+		return 0, ErrClientConnClosed
+	}
+
+	if header, ok := parseHeader(b); ok {
+		key := routeKey{
+			target:   header.Target,
+			source:   header.Source,
+			sequence: header.Sequence,
+		}
+		cc.lock.Lock()
+		cc.keys[key] = true
+		cc.lock.Unlock()
+		// Register before writing so that we won't miss fast responses.
+		cc.client.register(cc, key)
+	}
+
+	return cc.client.conn.WriteTo(b, cc.raddr)
+}
+
+// Close releases all the routes registered by this connection.
+//
+// It does not close the underlying shared socket.
+func (cc *clientConn) Close() error {
+	cc.closeOnce.Do(func() {
+		close(cc.closed)
+		cc.lock.Lock()
+		defer cc.lock.Unlock()
+		cc.client.unregister(cc, cc.keys)
+	})
+	return nil
+}
+
+func (cc *clientConn) LocalAddr() net.Addr {
+	return cc.client.LocalAddr()
+}
+
+func (cc *clientConn) RemoteAddr() net.Addr {
+	return cc.raddr
+}
+
+func (cc *clientConn) SetDeadline(t time.Time) error {
+	return cc.SetReadDeadline(t)
+}
+
+func (cc *clientConn) SetReadDeadline(t time.Time) error {
+	cc.lock.Lock()
+	defer cc.lock.Unlock()
+	cc.readDeadline = t
+	return nil
+}
+
+// SetWriteDeadline is a no-op as writes to the shared socket don't block.
+func (cc *clientConn) SetWriteDeadline(t time.Time) error {
+	return nil
+}
@@ -0,0 +1,134 @@
+package lifxlan_test
+
+import (
+	"context"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"go.yhsif.com/lifxlan"
+	"go.yhsif.com/lifxlan/mock"
+)
+
+func newClient(t *testing.T, options ...lifxlan.ClientOption) *lifxlan.Client {
+	t.Helper()
+
+	conn, err := net.ListenPacket("udp", mock.ListenAddr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := lifxlan.NewClient(conn, options...)
+	t.Cleanup(func() {
+		client.Close()
+	})
+	return client
+}
+
+func TestClient(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping test in short mode.")
+	}
+
+	const timeout = time.Millisecond * 500
+	const n = 20
+
+	client := newClient(t)
+
+	powers := []lifxlan.Power{lifxlan.PowerOn, lifxlan.PowerOff}
+	devices := make([]lifxlan.Device, len(powers))
+	for i, power := range powers {
+		var service *mock.Service
+		service, devices[i] = mock.StartService(t)
+		service.RawStatePowerPayload = &lifxlan.RawStatePowerPayload{
+			Level: power,
+		}
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		for j, device := range devices {
+			expected := powers[j]
+			wg.Add(2)
+			go func(device lifxlan.Device) {
+				defer wg.Done()
+
+				conn, err := client.DialDevice(device)
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				defer conn.Close()
+
+				ctx, cancel := context.WithTimeout(context.Background(), timeout)
+				defer cancel()
+
+				power, err := device.GetPower(ctx, conn)
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				if power != expected {
+					t.Errorf("Power expected %v, got %v", expected, power)
+				}
+			}(device)
+			go func(device lifxlan.Device) {
+				defer wg.Done()
+
+				conn, err := client.DialDevice(device)
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				defer conn.Close()
+
+				ctx, cancel := context.WithTimeout(context.Background(), timeout)
+				defer cancel()
+
+				if err := device.Echo(ctx, conn, nil); err != nil {
+					t.Error(err)
+				}
+			}(device)
+		}
+	}
+	wg.Wait()
+}
+
+func TestClientConnClosed(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping test in short mode.")
+	}
+
+	const timeout = time.Millisecond * 200
+
+	client := newClient(t)
+	_, device := mock.StartService(t)
+
+	conn, err := client.DialDevice(device)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	if _, err := device.GetPower(ctx, conn); err == nil {
+		t.Error("Expected error on closed connection, got nil")
+	} else {
+		t.Logf("Got error: %v", err)
+	}
+}
+
+func TestClientDialNetwork(t *testing.T) {
+	client := newClient(t)
+
+	if _, err := client.Dial("tcp", "127.0.0.1:56700"); err == nil {
+		t.Error("Expected error on tcp network, got nil")
+	}
+	conn, err := client.Dial("udp", "127.0.0.1:56700")
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn.Close()
+}
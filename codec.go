@@ -0,0 +1,101 @@
+package lifxlan
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+)
+
+// messageInfo is the registered info of a MessageType.
+type messageInfo struct {
+	name string
+	// nil means the message has no payload.
+	payload reflect.Type
+}
+
+var registry = struct {
+	sync.RWMutex
+
+	messages map[MessageType]messageInfo
+}{
+	messages: make(map[MessageType]messageInfo),
+}
+
+// RegisterMessage registers the name and the payload type of a MessageType,
+// to be used by MessageType.String and Decode.
+//
+// payload should be a value of (or a pointer to) the payload struct,
+// e.g. RawStatePowerPayload{},
+// or nil if the message doesn't have a payload.
+// Registering the same MessageType again overrides the previous registration.
+//
+// MessageType values defined in this package and its subpackages are
+// registered when the package is imported.
+// Third party packages implementing missing device APIs can register their
+// own MessageType values, usually in their init functions.
+// It's safe to be called concurrently.
+func RegisterMessage(message MessageType, name string, payload interface{}) {
+	var typ reflect.Type
+	if payload != nil {
+		typ = reflect.TypeOf(payload)
+		for typ.Kind() == reflect.Ptr {
+			typ = typ.Elem()
+		}
+	}
+
+	registry.Lock()
+	defer registry.Unlock()
+	registry.messages[message] = messageInfo{
+		name:    name,
+		payload: typ,
+	}
+}
+
+func lookupMessage(message MessageType) (info messageInfo, ok bool) {
+	registry.RLock()
+	defer registry.RUnlock()
+	info, ok = registry.messages[message]
+	return
+}
+
+// Name returns the registered name of the MessageType.
+//
+// If the MessageType is not registered, it returns empty string.
+func (m MessageType) Name() string {
+	info, _ := lookupMessage(m)
+	return info.name
+}
+
+func (m MessageType) String() string {
+	if name := m.Name(); name != "" {
+		return fmt.Sprintf("%s(%d)", name, uint16(m))
+	}
+	return fmt.Sprintf("<UNKNOWN> (%d)", uint16(m))
+}
+
+// Decode decodes the payload of the response into the payload struct
+// registered via RegisterMessage.
+//
+// The returned value is a pointer to the payload struct,
+// e.g. *RawStatePowerPayload for StatePower messages,
+// or nil if the message is registered without a payload.
+//
+// It returns an error if the message type is not registered,
+// or the payload cannot be decoded into the registered type.
+func Decode(resp *Response) (interface{}, error) {
+	info, ok := lookupMessage(resp.Message)
+	if !ok {
+		return nil, fmt.Errorf(
+			"lifxlan.Decode: unregistered message type %v",
+			resp.Message,
+		)
+	}
+	if info.payload == nil {
+		return nil, nil
+	}
+	v := reflect.New(info.payload).Interface()
+	if err := resp.DecodePayload(v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
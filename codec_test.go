@@ -0,0 +1,137 @@
+package lifxlan_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"reflect"
+	"testing"
+
+	"go.yhsif.com/lifxlan"
+	"go.yhsif.com/lifxlan/light"
+)
+
+func TestMessageTypeString(t *testing.T) {
+	cases := map[lifxlan.MessageType]string{
+		lifxlan.StatePower:         "StatePower(22)",
+		lifxlan.Acknowledgement:    "Acknowledgement(45)",
+		light.State:                "light.State(107)",
+		lifxlan.MessageType(65535): "<UNKNOWN> (65535)",
+	}
+
+	for msg, expected := range cases {
+		t.Run(
+			expected,
+			func(t *testing.T) {
+				if got := msg.String(); got != expected {
+					t.Errorf("Expected %q, got %q", expected, got)
+				}
+			},
+		)
+	}
+}
+
+func TestDecode(t *testing.T) {
+	makeResponse := func(t *testing.T, msg lifxlan.MessageType, payload interface{}) *lifxlan.Response {
+		t.Helper()
+
+		buf := new(bytes.Buffer)
+		if payload != nil {
+			if err := binary.Write(buf, binary.LittleEndian, payload); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return &lifxlan.Response{
+			Message: msg,
+			Payload: buf.Bytes(),
+		}
+	}
+
+	t.Run(
+		"Root",
+		func(t *testing.T) {
+			expected := &lifxlan.RawStatePowerPayload{
+				Level: lifxlan.PowerOn,
+			}
+			got, err := lifxlan.Decode(makeResponse(t, lifxlan.StatePower, expected))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, expected) {
+				t.Errorf("Expected %#v, got %#v", expected, got)
+			}
+		},
+	)
+
+	t.Run(
+		"Subpackage",
+		func(t *testing.T) {
+			expected := &light.RawStatePayload{
+				Color: lifxlan.Color{
+					Hue:        1,
+					Saturation: 2,
+					Brightness: 3,
+					Kelvin:     4,
+				},
+				Power: lifxlan.PowerOn,
+			}
+			expected.Label.Set("foo")
+			got, err := lifxlan.Decode(makeResponse(t, light.State, expected))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, expected) {
+				t.Errorf("Expected %#v, got %#v", expected, got)
+			}
+		},
+	)
+
+	t.Run(
+		"NoPayload",
+		func(t *testing.T) {
+			got, err := lifxlan.Decode(makeResponse(t, lifxlan.Acknowledgement, nil))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != nil {
+				t.Errorf("Expected nil, got %#v", got)
+			}
+		},
+	)
+
+	t.Run(
+		"Unregistered",
+		func(t *testing.T) {
+			_, err := lifxlan.Decode(makeResponse(t, lifxlan.MessageType(65535), nil))
+			if err == nil {
+				t.Error("Expected error for unregistered message type, got nil")
+			}
+		},
+	)
+
+	t.Run(
+		"Register",
+		func(t *testing.T) {
+			type payload struct {
+				Foo uint32
+				Bar uint16
+			}
+			const msg lifxlan.MessageType = 65534
+			lifxlan.RegisterMessage(msg, "Foo", &payload{})
+
+			if got := msg.String(); got != "Foo(65534)" {
+				t.Errorf("Expected %q, got %q", "Foo(65534)", got)
+			}
+			expected := &payload{
+				Foo: 1,
+				Bar: 2,
+			}
+			got, err := lifxlan.Decode(makeResponse(t, msg, expected))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, expected) {
+				t.Errorf("Expected %#v, got %#v", expected, got)
+			}
+		},
+	)
+}
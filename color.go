@@ -1,7 +1,9 @@
 package lifxlan
 
 import (
+	"encoding/binary"
 	"image/color"
+	"io"
 	"math"
 )
 
@@ -15,6 +17,36 @@ type Color struct {
 	Kelvin     uint16
 }
 
+// ColorLength is the length of the encoded Color.
+const ColorLength = 8
+
+// MarshalBinary implements encoding.BinaryMarshaler.
+func (c *Color) MarshalBinary() ([]byte, error) {
+	return c.AppendBinary(make([]byte, 0, ColorLength))
+}
+
+// AppendBinary implements BinaryAppender.
+func (c *Color) AppendBinary(b []byte) ([]byte, error) {
+	b, buf := grow(b, ColorLength)
+	binary.LittleEndian.PutUint16(buf[0:], c.Hue)
+	binary.LittleEndian.PutUint16(buf[2:], c.Saturation)
+	binary.LittleEndian.PutUint16(buf[4:], c.Brightness)
+	binary.LittleEndian.PutUint16(buf[6:], c.Kelvin)
+	return b, nil
+}
+
+// UnmarshalBinary implements encoding.BinaryUnmarshaler.
+func (c *Color) UnmarshalBinary(data []byte) error {
+	if len(data) < ColorLength {
+		return io.ErrUnexpectedEOF
+	}
+	c.Hue = binary.LittleEndian.Uint16(data[0:])
+	c.Saturation = binary.LittleEndian.Uint16(data[2:])
+	c.Brightness = binary.LittleEndian.Uint16(data[4:])
+	c.Kelvin = binary.LittleEndian.Uint16(data[6:])
+	return nil
+}
+
 // ColorBlack is the black color.
 var ColorBlack = *FromColor(color.Black, 0)
 
@@ -0,0 +1,133 @@
+package lifxlan
+
+import (
+	"context"
+	"time"
+)
+
+// Logger is used to log events that are not errors returned to the caller,
+// e.g. messages dropped by Client.
+//
+// *log.Logger satisfies this interface.
+type Logger interface {
+	Printf(format string, args ...interface{})
+}
+
+// Config defines the configuration used by API calls.
+//
+// It can be attached to a device via WithConfig option,
+// to a Client via WithClientConfig option,
+// or to a context via ContextWithConfig.
+//
+// The zero value uses the package-level defaults for all fields.
+// A Config shall not be modified after it's attached.
+type Config struct {
+	// How long a single read blocks before checking context cancellation.
+	//
+	// Values <= 0 mean UDPReadTimeout.
+	ReadTimeout time.Duration
+
+	// The buffer size used to read messages.
+	//
+	// Values <= 0 mean ResponseReadBufferSize.
+	ReadBufferSize int
+
+	// The initial RetryPolicy of the device it's attached to.
+	//
+	// Not used by Client or contexts.
+	Retry RetryPolicy
+
+	// If non-nil, a new RateLimiter with this RateLimit will be set on the
+	// device it's attached to.
+	//
+	// Not used by Client or contexts.
+	RateLimit *RateLimit
+
+	// The logger, nil means no logging.
+	Logger Logger
+}
+
+// defaultConfig is the Config used when there's no Config attached.
+var defaultConfig = new(Config)
+
+// ReadDeadline returns a value can be used in net.Conn.SetReadDeadline from
+// ReadTimeout.
+func (c *Config) ReadDeadline() time.Time {
+	if c.ReadTimeout > 0 {
+		return time.Now().Add(c.ReadTimeout)
+	}
+	return GetReadDeadline()
+}
+
+// BufferSize returns the buffer size to be used to read messages.
+func (c *Config) BufferSize() int {
+	if c.ReadBufferSize > 0 {
+		return c.ReadBufferSize
+	}
+	return ResponseReadBufferSize
+}
+
+// Logf logs with Logger if it's non-nil.
+func (c *Config) Logf(format string, args ...interface{}) {
+	if c.Logger != nil {
+		c.Logger.Printf(format, args...)
+	}
+}
+
+// readBuffer returns a buffer of BufferSize,
+// and the function to be called after the buffer is no longer used.
+func (c *Config) readBuffer() (buf []byte, done func()) {
+	size := c.BufferSize()
+	if size > ResponseReadBufferSize {
+		return make([]byte, size), func() {}
+	}
+	bufp := bufPool.Get().(*[]byte)
+	return (*bufp)[:size], func() {
+		bufPool.Put(bufp)
+	}
+}
+
+type configKey struct{}
+
+// ContextWithConfig returns a copy of ctx with config attached.
+//
+// If config is nil, ctx is returned as-is.
+func ContextWithConfig(ctx context.Context, config *Config) context.Context {
+	if config == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, configKey{}, config)
+}
+
+// ConfigFromContext returns the Config attached to ctx.
+//
+// It's guaranteed to be non-nil,
+// the zero value Config is returned when there's no Config attached.
+func ConfigFromContext(ctx context.Context) *Config {
+	if config, ok := ctx.Value(configKey{}).(*Config); ok {
+		return config
+	}
+	return defaultConfig
+}
+
+// WithConfig is a DeviceOption to attach config to the device.
+//
+// The device's RetryPolicy is initialized from config.Retry,
+// and if config.RateLimit is non-nil,
+// a new RateLimiter is set on the device.
+func WithConfig(config *Config) DeviceOption {
+	return func(d *device) {
+		d.config = config
+		if config == nil {
+			return
+		}
+		d.retry = config.Retry
+		if config.RateLimit != nil {
+			d.SetRateLimiter(NewRateLimiter(*config.RateLimit))
+		}
+	}
+}
+
+func (d *device) Config() *Config {
+	return d.config
+}
@@ -0,0 +1,174 @@
+package lifxlan_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"go.yhsif.com/lifxlan"
+)
+
+type recordLogger struct {
+	lock sync.Mutex
+	logs []string
+}
+
+func (l *recordLogger) Printf(format string, args ...interface{}) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	l.logs = append(l.logs, fmt.Sprintf(format, args...))
+}
+
+func (l *recordLogger) get() []string {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	return append([]string(nil), l.logs...)
+}
+
+func TestConfig(t *testing.T) {
+	t.Run(
+		"Defaults",
+		func(t *testing.T) {
+			config := lifxlan.ConfigFromContext(context.Background())
+			if config == nil {
+				t.Fatal("ConfigFromContext expected non-nil")
+			}
+			if size := config.BufferSize(); size != lifxlan.ResponseReadBufferSize {
+				t.Errorf(
+					"BufferSize() expected %d, got %d",
+					lifxlan.ResponseReadBufferSize,
+					size,
+				)
+			}
+			deadline := config.ReadDeadline()
+			if d := time.Until(deadline); d <= 0 || d > lifxlan.UDPReadTimeout {
+				t.Errorf("ReadDeadline() expected within %v, got %v", lifxlan.UDPReadTimeout, d)
+			}
+			ctx := context.Background()
+			if got := lifxlan.ContextWithConfig(ctx, nil); got != ctx {
+				t.Errorf("ContextWithConfig(ctx, nil) expected ctx, got %v", got)
+			}
+		},
+	)
+
+	t.Run(
+		"Device",
+		func(t *testing.T) {
+			config := &lifxlan.Config{
+				Retry: lifxlan.RetryPolicy{
+					Attempts:       3,
+					AttemptTimeout: time.Millisecond * 10,
+				},
+				RateLimit: &lifxlan.DefaultRateLimit,
+			}
+			d := lifxlan.NewDevice(
+				"",
+				lifxlan.ServiceUDP,
+				lifxlan.Target(1),
+				lifxlan.WithConfig(config),
+			)
+			if d.Config() != config {
+				t.Errorf("Config() expected %p, got %p", config, d.Config())
+			}
+			if *d.RetryPolicy() != config.Retry {
+				t.Errorf("RetryPolicy() expected %+v, got %+v", config.Retry, *d.RetryPolicy())
+			}
+			if d.RateLimiter() == nil {
+				t.Fatal("RateLimiter() expected non-nil")
+			}
+			if limit := d.RateLimiter().Limit(); limit != lifxlan.DefaultRateLimit {
+				t.Errorf("RateLimiter().Limit() expected %+v, got %+v", lifxlan.DefaultRateLimit, limit)
+			}
+
+			other := lifxlan.NewDevice("", lifxlan.ServiceUDP, lifxlan.Target(1))
+			if other.Config() != nil {
+				t.Errorf("Config() expected nil, got %+v", other.Config())
+			}
+		},
+	)
+
+	t.Run(
+		"ReadBufferSize",
+		func(t *testing.T) {
+			msg, err := lifxlan.GenerateMessage(
+				lifxlan.NotTagged,
+				0, // source
+				lifxlan.Target(1),
+				0, // flags
+				0, // sequence
+				lifxlan.StatePower,
+				[]byte{0xff, 0xff},
+			)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			ctx := lifxlan.ContextWithConfig(
+				context.Background(),
+				&lifxlan.Config{
+					ReadBufferSize: lifxlan.HeaderLength,
+				},
+			)
+			conn := &queueConn{msgs: [][]byte{msg}}
+			if _, err := lifxlan.ReadNextResponse(ctx, conn); !errors.Is(err, lifxlan.ErrSizeMismatch) {
+				t.Errorf("Expected %v with small buffer, got %v", lifxlan.ErrSizeMismatch, err)
+			}
+
+			conn = &queueConn{msgs: [][]byte{msg}}
+			if _, err := lifxlan.ReadNextResponse(context.Background(), conn); err != nil {
+				t.Errorf("Expected nil error with default buffer, got %v", err)
+			}
+		},
+	)
+
+	t.Run(
+		"ClientLogger",
+		func(t *testing.T) {
+			if testing.Short() {
+				t.Skip("skipping test in short mode.")
+			}
+
+			const timeout = time.Millisecond * 200
+
+			logger := new(recordLogger)
+			client := newClient(t, lifxlan.WithClientConfig(&lifxlan.Config{
+				Logger: logger,
+			}))
+
+			msg, err := lifxlan.GenerateMessage(
+				lifxlan.NotTagged,
+				1234, // source
+				lifxlan.Target(1),
+				0, // flags
+				0, // sequence
+				lifxlan.StatePower,
+				[]byte{0xff, 0xff},
+			)
+			if err != nil {
+				t.Fatal(err)
+			}
+			conn, err := net.Dial("udp", client.LocalAddr().String())
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer conn.Close()
+			if _, err := conn.Write(msg); err != nil {
+				t.Fatal(err)
+			}
+
+			deadline := time.Now().Add(timeout)
+			for time.Now().Before(deadline) && len(logger.get()) == 0 {
+				time.Sleep(timeout / 20)
+			}
+			logs := logger.get()
+			if len(logs) != 1 || !strings.Contains(logs[0], "without matching route") {
+				t.Errorf("Expected a log about no matching route, got %q", logs)
+			}
+		},
+	)
+}
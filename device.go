@@ -24,6 +24,19 @@ func (s ServiceType) String() string {
 	}
 }
 
+// Network returns the network name of the service type,
+// as used by net.Dial.
+//
+// For unknown service types it returns empty string.
+func (s ServiceType) Network() string {
+	switch s {
+	default:
+		return ""
+	case ServiceUDP:
+		return "udp"
+	}
+}
+
 // Device defines the common interface between lifxlan devices.
 //
 // For the Foo() and GetFoo() function pairs (e.g. Label() and GetLabel()),
@@ -64,11 +77,40 @@ func (s ServiceType) String() string {
 // In case of network error (e.g. response packet loss),
 // the GetFoo() functions might block until the context is cancelled,
 // as a result, it's a good idea to set a timeout to the context.
+// Setting a RetryPolicy on the device makes the API calls retransmit their
+// messages instead of waiting for the whole timeout on a single packet loss.
 type Device interface {
 	// Target returns the target of this device, usually it's the MAC address.
 	Target() Target
 
-	// Dial tries to establish a connection to this device.
+	// Addr returns the network address of this device.
+	//
+	// It could change after Refresh.
+	Addr() net.Addr
+
+	// Refresh re-resolves the network address of this device by its target,
+	// in case the device got a new address (e.g. a new DHCP lease).
+	//
+	// It broadcasts GetService using the DiscoverOptions set by
+	// WithRefreshOptions option (or the zero value),
+	// and updates the address from the first StateService response from this
+	// device's target.
+	// The cached properties (e.g. label), source,
+	// and everything else of the device are kept.
+	// Connections dialed before Refresh still use the old address.
+	//
+	// It returns ErrDeviceNotFound (wrapped) if ctx's deadline is reached
+	// before the device replied.
+	// If ctx doesn't have a deadline,
+	// it only returns after the device replied or ctx is cancelled.
+	//
+	// See WithAutoRefresh option to call it automatically after repeated
+	// failures.
+	Refresh(ctx context.Context) error
+
+	// Dial tries to establish a connection to this device,
+	// using the Transport set by WithTransport option,
+	// or DefaultTransport.
 	Dial() (net.Conn, error)
 
 	// Source returns a consistent random source to be used with API calls.
@@ -76,17 +118,111 @@ type Device interface {
 	Source() uint32
 
 	// NextSequence returns the next sequence value to be used with API calls.
+	//
+	// It skips the sequences currently in flight (see AcquireSequence),
+	// unless all of them are in flight.
 	NextSequence() uint8
 
+	// AcquireSequence returns the next sequence value not in flight,
+	// and marks it in flight.
+	//
+	// It's used for messages expecting replies (responses or acks),
+	// so that a stale reply to an earlier message cannot be matched by a later
+	// message using the same sequence after the uint8 wraps around.
+	//
+	// If all 256 sequences are in flight,
+	// it blocks until one of them is released or expired,
+	// or until ctx is done, in which case ctx.Err() is returned.
+	//
+	// The acquired sequence stays in flight until it's released by
+	// ReleaseSequence, or until ctx's deadline
+	// (or DefaultSequenceLease if ctx has no deadline).
+	AcquireSequence(ctx context.Context) (uint8, error)
+
+	// ReleaseSequence marks a sequence acquired by AcquireSequence as no longer
+	// in flight.
+	//
+	// It should be called once the reply is received,
+	// or no longer expected.
+	ReleaseSequence(seq uint8)
+
+	// RetryPolicy returns the pointer to the retry policy used by API calls on
+	// this device, guaranteed to be non-nil.
+	//
+	// The zero value means no retransmissions.
+	// Wrapped devices (e.g. light.Device) share the same retry policy with the
+	// device they wrap.
+	RetryPolicy() *RetryPolicy
+
+	// RateLimiter returns the rate limiter used by Send on this device.
+	//
+	// nil means no rate limiting, which is the default.
+	RateLimiter() *RateLimiter
+
+	// SetRateLimiter sets the rate limiter used by Send on this device.
+	//
+	// Wrapped devices (e.g. tile.Device) share the same rate limiter with the
+	// device they wrap.
+	// The same RateLimiter can also be shared by multiple devices.
+	//
+	// To use the rate limit configured for the product of the device:
+	//
+	//     limit := lifxlan.RateLimitFor(*d.HardwareVersion(), lifxlan.DefaultRateLimit)
+	//     d.SetRateLimiter(lifxlan.NewRateLimiter(limit))
+	SetRateLimiter(limiter *RateLimiter)
+
+	// Tracer returns the Tracer used by API calls on this device.
+	//
+	// nil means falling back to the Tracer attached to the context,
+	// or the global Tracer.
+	// See Tracer for more details.
+	Tracer() Tracer
+
+	// SetTracer sets the Tracer used by API calls on this device.
+	//
+	// Wrapped devices (e.g. light.Device) share the same Tracer with the device
+	// they wrap.
+	SetTracer(tracer Tracer)
+
+	// LinkStats returns the link quality statistics collector of this device,
+	// guaranteed to be non-nil.
+	//
+	// Wrapped devices (e.g. light.Device) share the same LinkStats with the
+	// device they wrap.
+	LinkStats() *LinkStats
+
+	// Stats returns a snapshot of the link quality statistics of this device,
+	// collected from the API calls expecting responses or acks (e.g. Echo).
+	//
+	// It can be used to detect devices with poor connections.
+	Stats() Stats
+
+	// Config returns the Config attached to this device via WithConfig option.
+	//
+	// nil means falling back to the Config attached to the context,
+	// or the package-level defaults.
+	Config() *Config
+
 	// Send generates and sends a message to the device.
 	//
 	// conn must be pre-dialed or this function will fail.
 	//
 	// It calls the device's Target(), Source(), and NextSequence() functions to
 	// fill the appropriate headers.
+	// If flags has FlagAckRequired or FlagResRequired,
+	// or the ExpectReply option is used,
+	// AcquireSequence() is used instead of NextSequence(),
+	// and the caller should call ReleaseSequence() with the returned sequence
+	// once the reply is received or no longer expected.
+	//
+	// If the device has a RateLimiter,
+	// it blocks or fails according to the RateLimitPolicy when the rate limit is
+	// reached.
+	//
+	// The sent message will be reported to the Tracer.
 	//
 	// The sequence used in this message will be returned.
-	Send(ctx context.Context, conn net.Conn, flags AckResFlag, message MessageType, payload interface{}) (seq uint8, err error)
+	Send(ctx context.Context, conn net.Conn, flags AckResFlag, message MessageType, payload interface{}, options ...SendOption) (seq uint8, err error)
 
 	// SanitizeColor tries to sanitize (keep values inside appropriate boundaries)
 	// color based on the device's feature, if available.
@@ -132,10 +268,41 @@ type Device interface {
 	// this function will only return nil error after it received ack from the
 	// device.
 	SetPower(ctx context.Context, conn net.Conn, power Power, ack bool) error
+	// SetPowerAndGet is similar to SetPower,
+	// but requests the device to reply with the resulting power level,
+	// and returns it.
+	//
+	// If conn is nil,
+	// a new connection will be made and guaranteed to be closed before returning.
+	// You should pre-dial and pass in the conn if you plan to call APIs on this
+	// device repeatedly.
+	//
+	// The message will be retransmitted according to RetryPolicy() until the
+	// response is received.
+	SetPowerAndGet(ctx context.Context, conn net.Conn, power Power) (Power, error)
 
 	// The label of the device.
 	Label() *Label
 	GetLabel(ctx context.Context, conn net.Conn) error
+	// SetLabel sets the label of the device,
+	// and updates the cached Label on success.
+	//
+	// If label is longer than LabelLength bytes,
+	// it returns the ErrLabelTruncated error from Label.Set without sending the
+	// message.
+	// To set a truncated label, use the String() of a Label Set with label.
+	//
+	// If conn is nil,
+	// a new connection will be made and guaranteed to be closed before returning.
+	// You should pre-dial and pass in the conn if you plan to call APIs on this
+	// device repeatedly.
+	//
+	// If ack is false,
+	// this function returns nil error after the API is sent successfully.
+	// If ack is true,
+	// this function will only return nil error after it received ack from the
+	// device.
+	SetLabel(ctx context.Context, conn net.Conn, label string, ack bool) error
 
 	// The hardware version info of the device.
 	HardwareVersion() *HardwareVersion
@@ -144,6 +311,38 @@ type Device interface {
 	// The firmware version of the device.
 	Firmware() *FirmwareUpgrade
 	GetFirmware(ctx context.Context, conn net.Conn) error
+
+	// The location of the device.
+	Location() *Membership
+	GetLocation(ctx context.Context, conn net.Conn) error
+	// SetLocation sets the location of the device,
+	// and updates the cached Location on success.
+	//
+	// To create a new location, use NewMembership.
+	// To move the device into an existing location,
+	// use the ID and Label of the existing location and Touch it first,
+	// see Membership for more details.
+	//
+	// If conn is nil,
+	// a new connection will be made and guaranteed to be closed before returning.
+	// You should pre-dial and pass in the conn if you plan to call APIs on this
+	// device repeatedly.
+	//
+	// If ack is false,
+	// this function returns nil error after the API is sent successfully.
+	// If ack is true,
+	// this function will only return nil error after it received ack from the
+	// device.
+	SetLocation(ctx context.Context, conn net.Conn, location Membership, ack bool) error
+
+	// The group of the device.
+	Group() *Membership
+	GetGroup(ctx context.Context, conn net.Conn) error
+	// SetGroup sets the group of the device,
+	// and updates the cached Group on success.
+	//
+	// See SetLocation for more details.
+	SetGroup(ctx context.Context, conn net.Conn, group Membership, ack bool) error
 }
 
 var _ Device = (*device)(nil)
@@ -151,32 +350,55 @@ var _ Device = (*device)(nil)
 // device defines the base type of a lifxlan device.
 type device struct {
 	// The network address, in "ip:port" format.
-	addr string
+	addr atomic.Value // string
 	// The type of service this device provides.
 	service ServiceType
 	// The target of this device, usually it's the MAC address.
 	target Target
 
-	source   uint32
-	sequence uint32
+	source    uint32
+	sequences sequenceTracker
+
+	transport Transport
+	config    *Config
+
+	refreshOpts  DiscoverOptions
+	refreshAfter int
+	refreshing   int32
+
+	retry   RetryPolicy
+	limiter atomic.Value // rateLimiterHolder
+	tracer  atomic.Value // tracerHolder
+	stats   LinkStats
 
 	// Cached properties.
 	label    Label
 	version  HardwareVersion
 	firmware FirmwareUpgrade
+	location Membership
+	group    Membership
 }
 
 // NewDevice creates a new Device.
 //
 // addr must be in "host:port" format and service must be a known service type,
 // otherwise the later Dial funcion will fail.
-func NewDevice(addr string, service ServiceType, target Target) Device {
-	return &device{
-		addr:    addr,
+func NewDevice(
+	addr string,
+	service ServiceType,
+	target Target,
+	options ...DeviceOption,
+) Device {
+	d := &device{
 		service: service,
 		target:  target,
 		source:  RandomSource(),
 	}
+	d.addr.Store(addr)
+	for _, opt := range options {
+		opt(d)
+	}
+	return d
 }
 
 func (d *device) String() string {
@@ -193,26 +415,47 @@ func (d *device) Target() Target {
 	return d.target
 }
 
+// deviceAddr implements net.Addr.
+type deviceAddr struct {
+	network string
+	addr    string
+}
+
+func (a deviceAddr) Network() string {
+	return a.network
+}
+
+func (a deviceAddr) String() string {
+	return a.addr
+}
+
+func (d *device) Addr() net.Addr {
+	return deviceAddr{
+		network: d.service.Network(),
+		addr:    d.address(),
+	}
+}
+
+func (d *device) address() string {
+	return d.addr.Load().(string)
+}
+
 func (d *device) Dial() (net.Conn, error) {
-	var network string
-	switch d.service {
-	default:
+	network := d.service.Network()
+	if network == "" {
 		return nil, fmt.Errorf(
-			"lifxlan.Device.Dial: unknown device service type: %v",
+			"lifxlan.Device.Dial: %w: %v",
+			ErrUnknownService,
 			d.service,
 		)
-	case ServiceUDP:
-		network = "udp"
 	}
-	return net.Dial(network, d.addr)
+	transport := d.transport
+	if transport == nil {
+		transport = DefaultTransport
+	}
+	return transport.Dial(network, d.address())
 }
 
 func (d *device) Source() uint32 {
 	return d.source
 }
-
-const uint8mask = uint32(0xff)
-
-func (d *device) NextSequence() uint8 {
-	return uint8(atomic.AddUint32(&d.sequence, 1) & uint8mask)
-}
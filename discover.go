@@ -1,11 +1,13 @@
 package lifxlan
 
 import (
-	"bytes"
 	"context"
 	"encoding/binary"
 	"fmt"
+	"io"
 	"net"
+	"sort"
+	"time"
 )
 
 // RawStateServicePayload defines the struct to be used for encoding and
@@ -17,12 +19,176 @@ type RawStateServicePayload struct {
 	Port    uint32
 }
 
+// MarshalBinary implements encoding.BinaryMarshaler.
+func (p *RawStateServicePayload) MarshalBinary() ([]byte, error) {
+	return p.AppendBinary(make([]byte, 0, 5))
+}
+
+// AppendBinary implements BinaryAppender.
+func (p *RawStateServicePayload) AppendBinary(b []byte) ([]byte, error) {
+	b, buf := grow(b, 5)
+	buf[0] = uint8(p.Service)
+	binary.LittleEndian.PutUint32(buf[1:], p.Port)
+	return b, nil
+}
+
+// UnmarshalBinary implements encoding.BinaryUnmarshaler.
+func (p *RawStateServicePayload) UnmarshalBinary(data []byte) error {
+	if len(data) < 5 {
+		return io.ErrUnexpectedEOF
+	}
+	p.Service = ServiceType(data[0])
+	p.Port = binary.LittleEndian.Uint32(data[1:])
+	return nil
+}
+
 // Default broadcast host and port.
 const (
 	DefaultBroadcastHost = "255.255.255.255"
 	DefaultBroadcastPort = "56700"
 )
 
+// DiscoverOptions defines the options used by DiscoverWithOptions.
+//
+// The zero value is the same as Discover with empty broadcastHost.
+type DiscoverOptions struct {
+	// The addresses to send the GetService broadcast to,
+	// in either "host" or "host:port" format.
+	// When the port is omitted, DefaultBroadcastPort will be used.
+	//
+	// When it's empty and AllInterfaces is false,
+	// DefaultBroadcastHost will be used.
+	BroadcastHosts []string
+
+	// When AllInterfaces is true,
+	// the GetService broadcast will also be sent to the directed broadcast
+	// address of every IPv4 network interface that is up and supports
+	// broadcast (see DirectedBroadcast).
+	AllInterfaces bool
+
+	// The offsets from the start of the discovery to (re)send the GetService
+	// broadcast, e.g. []time.Duration{0, time.Second, 3 * time.Second}.
+	//
+	// Devices missing the earlier broadcasts can still be discovered by the
+	// later ones.
+	// Offsets larger than the context deadline are never reached.
+	// When it's empty, the broadcast will only be sent once at the start.
+	Schedule []time.Duration
+
+	// The options used to create the discovered devices.
+	DeviceOptions []DeviceOption
+
+	// The local address to listen on for the StateService responses.
+	//
+	// Devices reply to the source port of the GetService broadcast,
+	// so an ephemeral port (the default when it's empty) works,
+	// and it won't conflict with other LIFX apps running on the same host.
+	// Set it to DiscoverListenAddrFixed to use the well-known port
+	// (the behavior before), e.g. to also receive the StateService responses
+	// to the broadcasts sent by other apps.
+	// On other ports, the responses to other sources are dropped.
+	ListenAddr string
+
+	// Only used by DiscoverAll.
+	//
+	// When QuietPeriod > 0,
+	// DiscoverAll returns after no new device is found for QuietPeriod,
+	// counting from the last scheduled broadcast,
+	// or when ctx is done, whichever comes first.
+	QuietPeriod time.Duration
+}
+
+// Values for DiscoverOptions.ListenAddr.
+const (
+	// An ephemeral port on all the local addresses.
+	DiscoverListenAddrEphemeral = ":0"
+
+	// The well-known port DefaultBroadcastPort on all the local addresses.
+	DiscoverListenAddrFixed = ":" + DefaultBroadcastPort
+)
+
+// DirectedBroadcast returns the directed broadcast address of an IPv4
+// network,
+// e.g. 192.168.1.255 for 192.168.1.0/24.
+//
+// It returns nil if n is not an IPv4 network.
+func DirectedBroadcast(n *net.IPNet) net.IP {
+	ip := n.IP.To4()
+	if ip == nil || len(n.Mask) != net.IPv4len {
+		return nil
+	}
+	broadcast := make(net.IP, net.IPv4len)
+	for i := range ip {
+		broadcast[i] = ip[i] | ^n.Mask[i]
+	}
+	return broadcast
+}
+
+// interfaceBroadcasts returns the directed broadcast addresses of all the IPv4
+// network interfaces that are up and support broadcast.
+func interfaceBroadcasts() ([]string, error) {
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return nil, err
+	}
+	var hosts []string
+	for _, iface := range ifaces {
+		if iface.Flags&net.FlagUp == 0 ||
+			iface.Flags&net.FlagBroadcast == 0 ||
+			iface.Flags&net.FlagLoopback != 0 {
+			continue
+		}
+		addrs, err := iface.Addrs()
+		if err != nil {
+			return nil, err
+		}
+		for _, addr := range addrs {
+			n, ok := addr.(*net.IPNet)
+			if !ok {
+				continue
+			}
+			if broadcast := DirectedBroadcast(n); broadcast != nil {
+				hosts = append(hosts, broadcast.String())
+			}
+		}
+	}
+	return hosts, nil
+}
+
+// broadcastAddrs resolves all the broadcast addresses from the options,
+// without duplications.
+func (opts DiscoverOptions) broadcastAddrs() ([]*net.UDPAddr, error) {
+	hosts := opts.BroadcastHosts
+	if opts.AllInterfaces {
+		ifaceHosts, err := interfaceBroadcasts()
+		if err != nil {
+			return nil, err
+		}
+		hosts = append(append([]string(nil), hosts...), ifaceHosts...)
+	}
+	if len(hosts) == 0 {
+		hosts = []string{DefaultBroadcastHost}
+	}
+
+	seen := make(map[string]bool)
+	addrs := make([]*net.UDPAddr, 0, len(hosts))
+	for _, host := range hosts {
+		if _, _, err := net.SplitHostPort(host); err != nil {
+			host = net.JoinHostPort(host, DefaultBroadcastPort)
+		}
+		addr, err := net.ResolveUDPAddr("udp", host)
+		if err != nil {
+			return nil, err
+		}
+		if seen[addr.String()] {
+			continue
+		}
+		seen[addr.String()] = true
+		addrs = append(addrs, addr)
+	}
+	return addrs, nil
+}
+
 // Discover discovers lifx products in the lan.
 //
 // When broadcastHost is empty (""), DefaultBroadcastHost will be used instead.
@@ -47,13 +213,53 @@ const (
 //       // Do something with device
 //     }
 //
+// DiscoverAll is a simpler alternative that returns a deduplicated list of
+// the devices found.
+//
+// It listens on an ephemeral port for the responses,
+// use DiscoverWithOptions with ListenAddr to change that.
+//
 // The function will only return upon error or when ctx is cancelled.
 // It's the caller's responsibility to make sure that the context is cancelled
 // (e.g. Use context.WithTimeout).
+//
+// The read timeout and buffer size are from the Config from the context.
+// The sent and received messages are reported to the Tracer from the context.
 func Discover(
 	ctx context.Context,
 	devices chan Device,
 	broadcastHost string,
+) error {
+	var opts DiscoverOptions
+	if broadcastHost != "" {
+		opts.BroadcastHosts = []string{broadcastHost}
+	}
+	return DiscoverWithOptions(ctx, devices, opts)
+}
+
+// DiscoverWithOptions is similar to Discover,
+// but with more control over where and when the GetService broadcast is sent.
+//
+// As the same device could reply to multiple broadcasts,
+// the same device (same target and address) will only be written into devices
+// channel once.
+func DiscoverWithOptions(
+	ctx context.Context,
+	devices chan Device,
+	opts DiscoverOptions,
+) error {
+	return discover(ctx, devices, opts, AllDevices)
+}
+
+// discover implements DiscoverWithOptions.
+//
+// When target is not AllDevices,
+// the responses from other targets are dropped.
+func discover(
+	ctx context.Context,
+	devices chan Device,
+	opts DiscoverOptions,
+	target Target,
 ) error {
 	defer close(devices)
 
@@ -61,59 +267,105 @@ func Discover(
 		return ctx.Err()
 	}
 
-	msg, err := GenerateMessage(
-		Tagged,
-		0, // source
-		AllDevices,
-		0, // flags
-		0, // sequence
-		GetService,
-		nil, // payload
-	)
+	// Devices could broadcast the replies to DefaultBroadcastPort when source
+	// is 0, which never reach an ephemeral port.
+	header := RawHeader{
+		Size:   HeaderLength,
+		Tagged: Tagged,
+		Source: RandomSource(),
+		Target: AllDevices,
+		Type:   GetService,
+	}
+	msg, err := header.MarshalBinary()
 	if err != nil {
 		return err
 	}
 
-	conn, err := net.ListenPacket("udp", ":"+DefaultBroadcastPort)
+	listenAddr := opts.ListenAddr
+	if listenAddr == "" {
+		listenAddr = DiscoverListenAddrEphemeral
+	}
+	conn, err := net.ListenPacket("udp", listenAddr)
 	if err != nil {
 		return err
 	}
 	defer conn.Close()
-
-	if broadcastHost == "" {
-		broadcastHost = DefaultBroadcastHost
+	// Only keep the replies to other sources on the well-known port,
+	// which could be the replies to the broadcasts sent by other apps.
+	_, port, err := net.SplitHostPort(conn.LocalAddr().String())
+	if err != nil {
+		return err
 	}
-	broadcast, err := net.ResolveUDPAddr(
-		"udp",
-		net.JoinHostPort(broadcastHost, DefaultBroadcastPort),
-	)
+	anySource := port == DefaultBroadcastPort
+
+	broadcasts, err := opts.broadcastAddrs()
 	if err != nil {
 		return err
 	}
+	schedule := opts.Schedule
+	if len(schedule) == 0 {
+		schedule = []time.Duration{0}
+	}
+	schedule = append([]time.Duration(nil), schedule...)
+	sort.Slice(schedule, func(i, j int) bool {
+		return schedule[i] < schedule[j]
+	})
 
 	if ctx.Err() != nil {
 		return ctx.Err()
 	}
 
-	n, err := conn.WriteTo(msg, broadcast)
-	if err != nil {
-		return err
+	tracer := TracerFromContext(ctx)
+	send := func() error {
+		for _, broadcast := range broadcasts {
+			n, err := conn.WriteTo(msg, broadcast)
+			if err != nil {
+				return err
+			}
+			if n < len(msg) {
+				return fmt.Errorf(
+					"lifxlan.Discover: only wrote %d out of %d bytes: %w",
+					n,
+					len(msg),
+					ErrShortWrite,
+				)
+			}
+			tracer.OnSend(AllDevices, header, nil)
+		}
+		return nil
 	}
-	if n < len(msg) {
-		return fmt.Errorf(
-			"lifxlan.Discover: only wrote %d out of %d bytes",
-			n,
-			len(msg),
-		)
+
+	type deviceKey struct {
+		target Target
+		addr   string
 	}
+	seen := make(map[deviceKey]bool)
 
-	buf := make([]byte, ResponseReadBufferSize)
+	start := time.Now()
+	config := ConfigFromContext(ctx)
+	buf := make([]byte, config.BufferSize())
 	for {
 		if ctx.Err() != nil {
 			return ctx.Err()
 		}
 
-		if err := conn.SetReadDeadline(GetReadDeadline()); err != nil {
+		if len(schedule) > 0 && time.Since(start) >= schedule[0] {
+			// Skip all the passed ones and only send once.
+			for len(schedule) > 0 && time.Since(start) >= schedule[0] {
+				schedule = schedule[1:]
+			}
+			if err := send(); err != nil {
+				return err
+			}
+		}
+
+		deadline := config.ReadDeadline()
+		if len(schedule) > 0 {
+			if next := start.Add(schedule[0]); next.Before(deadline) {
+				deadline = next
+			}
+		}
+		if err := conn.SetReadDeadline(deadline); err != nil {
 			return err
 		}
 		n, addr, err := conn.ReadFrom(buf)
@@ -131,27 +383,130 @@ func Discover(
 
 		resp, err := ParseResponse(buf[:n])
 		if err != nil {
-			return err
+			// Could be foreign or malformed datagrams,
+			// especially on a shared port.
+			config.Logf("lifxlan.Discover: skipping message from %v: %v", addr, err)
+			continue
+		}
+		tracer.OnReceive(resp)
+		if !anySource && resp.Source != header.Source {
+			tracer.OnDrop(DropWrongSource, resp)
+			continue
 		}
 		if resp.Message != StateService {
+			tracer.OnDrop(DropWrongType, resp)
+			continue
+		}
+		if target != AllDevices && resp.Target != target {
+			tracer.OnDrop(DropWrongTarget, resp)
 			continue
 		}
 
 		var d RawStateServicePayload
-		r := bytes.NewReader(resp.Payload)
-		if err := binary.Read(r, binary.LittleEndian, &d); err != nil {
-			return err
+		if err := d.UnmarshalBinary(resp.Payload); err != nil {
+			config.Logf("lifxlan.Discover: skipping message from %v: %v", addr, err)
+			continue
 		}
 		switch d.Service {
 		default:
 			// Unknown service, ignore.
+			tracer.OnDrop(DropUnknownService, resp)
 			continue
 		case ServiceUDP:
+			key := deviceKey{
+				target: resp.Target,
+				addr:   net.JoinHostPort(host, fmt.Sprintf("%d", d.Port)),
+			}
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
 			devices <- NewDevice(
-				net.JoinHostPort(host, fmt.Sprintf("%d", d.Port)),
+				key.addr,
 				d.Service,
 				resp.Target,
+				opts.DeviceOptions...,
 			)
 		}
 	}
 }
+
+// DiscoverAll discovers lifx products in the lan,
+// and returns the devices found.
+//
+// Devices are deduplicated by their targets,
+// when the same device replies from multiple addresses,
+// the first one is kept.
+// The devices are in the order they are discovered.
+//
+// It returns when ctx is done,
+// or after opts.QuietPeriod without new devices found.
+// Reaching ctx's deadline is treated as normal completion,
+// in which case a nil error is returned along with the devices found.
+// If ctx doesn't have a deadline and opts.QuietPeriod is not set,
+// it only returns after ctx is cancelled.
+func DiscoverAll(ctx context.Context, opts DiscoverOptions) ([]Device, error) {
+	discoverCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	devices := make(chan Device)
+	errChan := make(chan error, 1)
+	go func() {
+		errChan <- DiscoverWithOptions(discoverCtx, devices, opts)
+	}()
+
+	var quiet <-chan time.Time
+	var timer *time.Timer
+	// The earliest time the quiet period could end,
+	// counting from the last scheduled broadcast.
+	var quietAfter time.Time
+	if opts.QuietPeriod > 0 {
+		var last time.Duration
+		for _, offset := range opts.Schedule {
+			if offset > last {
+				last = offset
+			}
+		}
+		quietAfter = time.Now().Add(last + opts.QuietPeriod)
+		timer = time.NewTimer(last + opts.QuietPeriod)
+		defer timer.Stop()
+		quiet = timer.C
+	}
+
+	var found []Device
+	seen := make(map[Target]bool)
+	for {
+		select {
+		case d, ok := <-devices:
+			if !ok {
+				err := <-errChan
+				if err == context.DeadlineExceeded ||
+					(err == context.Canceled && ctx.Err() == nil) {
+					// Either ctx's deadline or the quiet period reached.
+					err = nil
+				}
+				return found, err
+			}
+			if seen[d.Target()] {
+				continue
+			}
+			seen[d.Target()] = true
+			found = append(found, d)
+			if quiet != nil {
+				if !timer.Stop() {
+					<-timer.C
+				}
+				// Don't end before the later scheduled broadcasts.
+				wait := opts.QuietPeriod
+				if untilLast := time.Until(quietAfter); untilLast > wait {
+					wait = untilLast
+				}
+				timer.Reset(wait)
+			}
+
+		case <-quiet:
+			quiet = nil
+			cancel()
+		}
+	}
+}
@@ -0,0 +1,467 @@
+package lifxlan_test
+
+import (
+	"context"
+	"net"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"go.yhsif.com/lifxlan"
+	"go.yhsif.com/lifxlan/mock"
+)
+
+func TestDirectedBroadcast(t *testing.T) {
+	for _, c := range []struct {
+		cidr     string
+		expected string
+	}{
+		{
+			cidr:     "192.168.1.10/24",
+			expected: "192.168.1.255",
+		},
+		{
+			cidr:     "10.1.2.3/8",
+			expected: "10.255.255.255",
+		},
+		{
+			cidr:     "172.16.5.4/20",
+			expected: "172.16.15.255",
+		},
+		{
+			cidr:     "fe80::1/64",
+			expected: "<nil>",
+		},
+	} {
+		t.Run(
+			c.cidr,
+			func(t *testing.T) {
+				ip, n, err := net.ParseCIDR(c.cidr)
+				if err != nil {
+					t.Fatal(err)
+				}
+				n.IP = ip
+				if actual := lifxlan.DirectedBroadcast(n).String(); actual != c.expected {
+					t.Errorf("Expected %s, got %s", c.expected, actual)
+				}
+			},
+		)
+	}
+}
+
+// startDiscoverService starts a mock service replying to GetService,
+// except the first drop ones.
+//
+// It returns the device,
+// and a function returning the number of GetService messages received.
+func startDiscoverService(t *testing.T, drop int) (lifxlan.Device, func() int) {
+	t.Helper()
+
+	_, device, received := startReplyingService(t, func(n int) bool {
+		return n > drop
+	})
+	return device, received
+}
+
+// startReplyingService starts a mock service replying to the n-th GetService
+// received when reply(n) returns true.
+//
+// It returns the service, the device,
+// and a function returning the number of GetService messages received.
+func startReplyingService(t *testing.T, reply func(n int) bool) (*mock.Service, lifxlan.Device, func() int) {
+	t.Helper()
+
+	service, device := mock.StartService(t)
+	_, portStr, err := net.SplitHostPort(device.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	port, err := strconv.Atoi(portStr)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var lock sync.Mutex
+	var received int
+	service.Handlers[lifxlan.GetService] = func(
+		s *mock.Service,
+		conn net.PacketConn,
+		addr net.Addr,
+		orig *lifxlan.Response,
+	) {
+		lock.Lock()
+		received++
+		n := received
+		lock.Unlock()
+		if !reply(n) {
+			return
+		}
+		payload, err := (&lifxlan.RawStateServicePayload{
+			Service: lifxlan.ServiceUDP,
+			Port:    uint32(port),
+		}).MarshalBinary()
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		s.Reply(conn, addr, orig, lifxlan.StateService, payload)
+	}
+	return service, device, func() int {
+		lock.Lock()
+		defer lock.Unlock()
+		return received
+	}
+}
+
+func TestDiscoverWithOptions(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping test in short mode.")
+	}
+
+	const timeout = time.Millisecond * 200
+
+	// Drop the first broadcast to verify the resends.
+	device, received := startDiscoverService(t, 1)
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	devices := make(chan lifxlan.Device)
+	errChan := make(chan error, 1)
+	go func() {
+		errChan <- lifxlan.DiscoverWithOptions(
+			ctx,
+			devices,
+			lifxlan.DiscoverOptions{
+				BroadcastHosts: []string{device.Addr().String()},
+				Schedule: []time.Duration{
+					0,
+					timeout / 4,
+					timeout / 2,
+				},
+			},
+		)
+	}()
+
+	var found []lifxlan.Device
+	for d := range devices {
+		found = append(found, d)
+	}
+	if err := <-errChan; err != context.DeadlineExceeded {
+		t.Fatal(err)
+	}
+
+	if n := received(); n != 3 {
+		t.Errorf("Expected 3 GetService messages, got %d", n)
+	}
+	if len(found) != 1 {
+		t.Fatalf("Expected 1 device, got %v", found)
+	}
+	if found[0].Target() != device.Target() || found[0].Addr().String() != device.Addr().String() {
+		t.Errorf("Expected device %v, got %v", device, found[0])
+	}
+}
+
+func TestDiscoverSource(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping test in short mode.")
+	}
+
+	const timeout = time.Millisecond * 200
+
+	service, device := mock.StartService(t)
+	_, portStr, err := net.SplitHostPort(device.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	port, err := strconv.Atoi(portStr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sources := make(chan uint32, 1)
+	service.Handlers[lifxlan.GetService] = func(
+		s *mock.Service,
+		conn net.PacketConn,
+		addr net.Addr,
+		orig *lifxlan.Response,
+	) {
+		sources <- orig.Source
+		payload, err := (&lifxlan.RawStateServicePayload{
+			Service: lifxlan.ServiceUDP,
+			Port:    uint32(port),
+		}).MarshalBinary()
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		// The reply to a different source is dropped.
+		wrongSource := *orig
+		wrongSource.Source++
+		s.Reply(conn, addr, &wrongSource, lifxlan.StateService, payload)
+	}
+
+	tracer := new(recordTracer)
+	ctx, cancel := context.WithTimeout(
+		lifxlan.WithTracer(context.Background(), tracer),
+		timeout,
+	)
+	defer cancel()
+	devices, err := lifxlan.DiscoverAll(ctx, lifxlan.DiscoverOptions{
+		BroadcastHosts: []string{device.Addr().String()},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(devices) != 0 {
+		t.Errorf("Expected no devices, got %v", devices)
+	}
+
+	select {
+	case source := <-sources:
+		if source == 0 {
+			t.Error("Expected non-zero source in GetService header")
+		}
+	default:
+		t.Fatal("GetService not received")
+	}
+	tracer.lock.Lock()
+	defer tracer.lock.Unlock()
+	var wrongSource bool
+	for _, reason := range tracer.dropped {
+		if reason == lifxlan.DropWrongSource {
+			wrongSource = true
+		}
+	}
+	if !wrongSource {
+		t.Errorf("Expected %v dropped, got %v", lifxlan.DropWrongSource, tracer.dropped)
+	}
+}
+
+func TestDiscoverInvalidMessages(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping test in short mode.")
+	}
+
+	const timeout = time.Millisecond * 200
+
+	service, device := mock.StartService(t)
+	_, portStr, err := net.SplitHostPort(device.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	port, err := strconv.Atoi(portStr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	service.Handlers[lifxlan.GetService] = func(
+		s *mock.Service,
+		conn net.PacketConn,
+		addr net.Addr,
+		orig *lifxlan.Response,
+	) {
+		// Foreign or malformed datagrams are skipped.
+		invalid, err := lifxlan.GenerateMessage(
+			lifxlan.NotTagged+1, // invalid protocol
+			orig.Source,
+			mock.Target,
+			0, // flags
+			orig.Sequence,
+			lifxlan.StateService,
+			nil, // payload
+		)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		for _, msg := range [][]byte{[]byte("foo"), invalid} {
+			if _, err := conn.WriteTo(msg, addr); err != nil {
+				t.Error(err)
+				return
+			}
+		}
+		// So is a StateService with short payload.
+		s.Reply(conn, addr, orig, lifxlan.StateService, []byte{1})
+
+		payload, err := (&lifxlan.RawStateServicePayload{
+			Service: lifxlan.ServiceUDP,
+			Port:    uint32(port),
+		}).MarshalBinary()
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		s.Reply(conn, addr, orig, lifxlan.StateService, payload)
+	}
+
+	logger := new(recordLogger)
+	ctx, cancel := context.WithTimeout(
+		lifxlan.ContextWithConfig(context.Background(), &lifxlan.Config{
+			Logger: logger,
+		}),
+		timeout,
+	)
+	defer cancel()
+	devices, err := lifxlan.DiscoverAll(ctx, lifxlan.DiscoverOptions{
+		BroadcastHosts: []string{device.Addr().String()},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(devices) != 1 || devices[0].Target() != mock.Target {
+		t.Errorf("Expected device %v, got %v", mock.Target, devices)
+	}
+	if logs := logger.get(); len(logs) != 3 {
+		t.Errorf("Expected 3 skipped messages logged, got %q", logs)
+	}
+}
+
+func TestDiscoverListenAddr(t *testing.T) {
+	const timeout = time.Millisecond * 50
+
+	taken, err := net.ListenPacket("udp", mock.ListenAddr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer taken.Close()
+
+	discover := func(listenAddr string) error {
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		defer cancel()
+
+		devices := make(chan lifxlan.Device)
+		go func() {
+			for range devices {
+			}
+		}()
+		return lifxlan.DiscoverWithOptions(
+			ctx,
+			devices,
+			lifxlan.DiscoverOptions{
+				BroadcastHosts: []string{taken.LocalAddr().String()},
+				ListenAddr:     listenAddr,
+			},
+		)
+	}
+
+	if err := discover(taken.LocalAddr().String()); err == context.DeadlineExceeded {
+		t.Error("Expected error listening on a taken address, got nil")
+	}
+	// Two concurrent discoveries should not conflict with each other.
+	var wg sync.WaitGroup
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := discover(""); err != context.DeadlineExceeded {
+				t.Errorf("Expected %v with ephemeral port, got %v", context.DeadlineExceeded, err)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestDiscoverAll(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping test in short mode.")
+	}
+
+	const timeout = time.Millisecond * 200
+
+	// Both mock services use mock.Target.
+	device1, _ := startDiscoverService(t, 0)
+	device2, _ := startDiscoverService(t, 0)
+	opts := lifxlan.DiscoverOptions{
+		BroadcastHosts: []string{
+			device1.Addr().String(),
+			device2.Addr().String(),
+		},
+		Schedule: []time.Duration{0, timeout / 8},
+	}
+
+	check := func(t *testing.T, devices []lifxlan.Device) {
+		t.Helper()
+		if len(devices) != 1 {
+			t.Fatalf("Expected 1 device, got %v", devices)
+		}
+		if devices[0].Target() != mock.Target {
+			t.Errorf("Expected target %v, got %v", mock.Target, devices[0].Target())
+		}
+	}
+
+	t.Run(
+		"Deadline",
+		func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), timeout)
+			defer cancel()
+
+			devices, err := lifxlan.DiscoverAll(ctx, opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			check(t, devices)
+		},
+	)
+
+	t.Run(
+		"QuietPeriod",
+		func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), timeout*10)
+			defer cancel()
+
+			opts := opts
+			opts.QuietPeriod = timeout / 4
+			start := time.Now()
+			devices, err := lifxlan.DiscoverAll(ctx, opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if elapsed := time.Since(start); elapsed > timeout*5 {
+				t.Errorf("Expected to return after the quiet period, took %v", elapsed)
+			}
+			check(t, devices)
+		},
+	)
+
+	t.Run(
+		"QuietPeriodBeforeLastSchedule",
+		func(t *testing.T) {
+			_, device, received := startReplyingService(t, func(int) bool {
+				return true
+			})
+
+			ctx, cancel := context.WithTimeout(context.Background(), timeout*10)
+			defer cancel()
+
+			opts := lifxlan.DiscoverOptions{
+				BroadcastHosts: []string{device.Addr().String()},
+				Schedule:       []time.Duration{0, timeout / 2},
+				QuietPeriod:    timeout / 10,
+			}
+			start := time.Now()
+			devices, err := lifxlan.DiscoverAll(ctx, opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if elapsed := time.Since(start); elapsed < timeout/2+timeout/10 {
+				t.Errorf("Expected to wait for the quiet period after the last broadcast, took %v", elapsed)
+			}
+			check(t, devices)
+			if n := received(); n != 2 {
+				t.Errorf("Expected 2 broadcasts received, got %d", n)
+			}
+		},
+	)
+
+	t.Run(
+		"Cancelled",
+		func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+
+			if _, err := lifxlan.DiscoverAll(ctx, opts); err != context.Canceled {
+				t.Errorf("Expected %v, got %v", context.Canceled, err)
+			}
+		},
+	)
+}
@@ -14,6 +14,8 @@
 // returned by discovery using only exported functions.
 // Please refer to the subpackages for an example of extending device
 // capabilities.
+// Request and Command are the round-trip primitives all the device APIs are
+// built on, and they are the recommended way to implement missing ones.
 //
 // The API is unstable right now,
 // but the maintainer tries very hard not to break them.
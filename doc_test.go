@@ -62,6 +62,54 @@ func Example_deviceDiscovery() {
 	wg.Wait()
 }
 
+// This example demonstrates how to implement a missing device API with
+// Request and Command.
+func Example_request() {
+	// Should actually be proper structs according to the Protocol definition.
+	type (
+		payloadType     struct{}
+		respPayloadType struct{}
+	)
+	// Config values that should be initialized with proper args in real code.
+	var (
+		// Should come with a timeout, or we might wait forever.
+		ctx context.Context
+		// The discovered device to use.
+		device lifxlan.Device
+		// The actual message types to be sent.
+		getMessage, setMessage lifxlan.MessageType
+		// The actual payload values.
+		payload payloadType
+		// The response message type.
+		respMessage lifxlan.MessageType
+	)
+
+	var raw respPayloadType
+	if err := lifxlan.Request(
+		ctx,
+		device,
+		nil, // conn, use nil so that Request will maintain it for us
+		getMessage,
+		nil, // payload, could be nil if this message doesn't need payload.
+		respMessage,
+		&raw,
+	); err != nil {
+		log.Fatal(err)
+	}
+	// TODO: handle payload value in raw
+
+	if err := lifxlan.Command(
+		ctx,
+		device,
+		nil, // conn, use nil so that Command will maintain it for us
+		setMessage,
+		&payload,
+		true, // ack
+	); err != nil {
+		log.Fatal(err)
+	}
+}
+
 // This example demonstrates how to send a message and wait for the ack.
 //
 // Please note that this example assumes that no other replies besides ack are
@@ -103,6 +151,8 @@ func Example_sendMessageWithAck() {
 	if err != nil {
 		log.Fatal(err)
 	}
+	// The sequence is in flight until released.
+	defer device.ReleaseSequence(seq)
 
 	if err := lifxlan.WaitForAcks(ctx, conn, device.Source(), seq); err != nil {
 		log.Fatal(err)
@@ -146,10 +196,13 @@ func Example_sendMessageWithResponse() {
 		0, // flags, not requiring ack because this message will get a response.
 		message,
 		&payload, // could be nil if this message doesn't need payload.
+		lifxlan.ExpectReply(),
 	)
 	if err != nil {
 		log.Fatal(err)
 	}
+	// The sequence is in flight until released.
+	defer device.ReleaseSequence(seq)
 
 	for {
 		resp, err := lifxlan.ReadNextResponse(ctx, conn)
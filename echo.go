@@ -3,8 +3,7 @@ package lifxlan
 import (
 	"bytes"
 	"context"
-	"encoding/binary"
-	"errors"
+	"io"
 	"math/rand"
 	"net"
 )
@@ -13,6 +12,32 @@ const (
 	EchoPayloadLength = 64
 )
 
+// RawEchoRequestPayload defines echo request payload according to:
+//
+// https://lan.developer.lifx.com/docs/querying-the-device-for-data#echorequest---packet-58
+type RawEchoRequestPayload struct {
+	Echoing [EchoPayloadLength]byte
+}
+
+// MarshalBinary implements encoding.BinaryMarshaler.
+func (p *RawEchoRequestPayload) MarshalBinary() ([]byte, error) {
+	return p.AppendBinary(make([]byte, 0, EchoPayloadLength))
+}
+
+// AppendBinary implements BinaryAppender.
+func (p *RawEchoRequestPayload) AppendBinary(b []byte) ([]byte, error) {
+	return append(b, p.Echoing[:]...), nil
+}
+
+// UnmarshalBinary implements encoding.BinaryUnmarshaler.
+func (p *RawEchoRequestPayload) UnmarshalBinary(data []byte) error {
+	if len(data) < EchoPayloadLength {
+		return io.ErrUnexpectedEOF
+	}
+	copy(p.Echoing[:], data)
+	return nil
+}
+
 // RawEchoResponsePayload defines echo response payload according to:
 //
 // https://lan.developer.lifx.com/docs/information-messages#echoresponse---packet-59
@@ -20,63 +45,47 @@ type RawEchoResponsePayload struct {
 	Echoing [EchoPayloadLength]byte
 }
 
-func (d *device) Echo(ctx context.Context, conn net.Conn, payload []byte) error {
-	if ctx.Err() != nil {
-		return ctx.Err()
-	}
+// MarshalBinary implements encoding.BinaryMarshaler.
+func (p *RawEchoResponsePayload) MarshalBinary() ([]byte, error) {
+	return p.AppendBinary(make([]byte, 0, EchoPayloadLength))
+}
 
-	if conn == nil {
-		newConn, err := d.Dial()
-		if err != nil {
-			return err
-		}
-		defer newConn.Close()
-		conn = newConn
+// AppendBinary implements BinaryAppender.
+func (p *RawEchoResponsePayload) AppendBinary(b []byte) ([]byte, error) {
+	return append(b, p.Echoing[:]...), nil
+}
 
-		if ctx.Err() != nil {
-			return ctx.Err()
-		}
+// UnmarshalBinary implements encoding.BinaryUnmarshaler.
+func (p *RawEchoResponsePayload) UnmarshalBinary(data []byte) error {
+	if len(data) < EchoPayloadLength {
+		return io.ErrUnexpectedEOF
 	}
+	copy(p.Echoing[:], data)
+	return nil
+}
 
+func (d *device) Echo(ctx context.Context, conn net.Conn, payload []byte) error {
 	body := make([]byte, EchoPayloadLength)
 	copy(body, payload)
 	if len(payload) < EchoPayloadLength {
 		rand.Read(body[len(payload):])
 	}
 
-	seq, err := d.Send(
+	var raw RawEchoResponsePayload
+	if err := Request(
 		ctx,
+		d,
 		conn,
-		0, // flags
 		EchoRequest,
 		body,
-	)
-	if err != nil {
+		EchoResponse,
+		&raw,
+	); err != nil {
 		return err
 	}
 
-	for {
-		resp, err := ReadNextResponse(ctx, conn)
-		if err != nil {
-			return err
-		}
-		if resp.Sequence != seq || resp.Source != d.Source() {
-			continue
-		}
-		if resp.Message != EchoResponse {
-			continue
-		}
-
-		var raw RawEchoResponsePayload
-		r := bytes.NewReader(resp.Payload)
-		if err := binary.Read(r, binary.LittleEndian, &raw); err != nil {
-			return err
-		}
-
-		if !bytes.Equal(raw.Echoing[:], body) {
-			return errors.New("unexpected echo response value")
-		}
-
-		return nil
+	if !bytes.Equal(raw.Echoing[:], body) {
+		return ErrEchoMismatch
 	}
+	return nil
 }
@@ -2,9 +2,12 @@ package lifxlan_test
 
 import (
 	"context"
+	"errors"
+	"net"
 	"testing"
 	"time"
 
+	"go.yhsif.com/lifxlan"
 	"go.yhsif.com/lifxlan/mock"
 )
 
@@ -15,7 +18,7 @@ func TestEcho(t *testing.T) {
 
 	const timeout = time.Millisecond * 200
 
-	_, device := mock.StartService(t)
+	service, device := mock.StartService(t)
 
 	ctx, cancel := context.WithTimeout(context.Background(), timeout)
 	defer cancel()
@@ -49,4 +52,30 @@ func TestEcho(t *testing.T) {
 			}
 		},
 	)
+
+	t.Run(
+		"Mismatch",
+		func(t *testing.T) {
+			service.Handlers[lifxlan.EchoRequest] = func(
+				s *mock.Service,
+				conn net.PacketConn,
+				addr net.Addr,
+				orig *lifxlan.Response,
+			) {
+				s.Reply(
+					conn,
+					addr,
+					orig,
+					lifxlan.EchoResponse,
+					make([]byte, lifxlan.EchoPayloadLength),
+				)
+			}
+			defer delete(service.Handlers, lifxlan.EchoRequest)
+
+			err := device.Echo(ctx, nil, []byte("payload"))
+			if !errors.Is(err, lifxlan.ErrEchoMismatch) {
+				t.Errorf("Expected ErrEchoMismatch, got %v", err)
+			}
+		},
+	)
 }
@@ -0,0 +1,74 @@
+package lifxlan
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/binary"
+	"sync"
+)
+
+// BinaryAppender is the interface implemented by types that can append their
+// binary encoding to a byte slice.
+//
+// It has the same signature as encoding.BinaryAppender from newer Go versions.
+//
+// All the Raw*Payload types in this package and its subpackages implement
+// BinaryAppender, encoding.BinaryMarshaler, and encoding.BinaryUnmarshaler
+// without using reflection,
+// and they produce the same result as encoding/binary with LittleEndian.
+// When unmarshaling, trailing bytes are ignored and short data causes
+// io.ErrUnexpectedEOF, also the same as encoding/binary.
+type BinaryAppender interface {
+	AppendBinary(b []byte) ([]byte, error)
+}
+
+// Make sure all the types in this package implement the interfaces.
+var (
+	_ BinaryAppender             = (*RawHeader)(nil)
+	_ encoding.BinaryMarshaler   = (*RawHeader)(nil)
+	_ encoding.BinaryUnmarshaler = (*RawHeader)(nil)
+)
+
+// bufPool is the pool of *[]byte with ResponseReadBufferSize capacity,
+// used by Send and ReadNextResponse.
+var bufPool = sync.Pool{
+	New: func() interface{} {
+		buf := make([]byte, ResponseReadBufferSize)
+		return &buf
+	},
+}
+
+// AppendPayload appends the binary encoding of payload to b.
+//
+// If payload implements BinaryAppender or encoding.BinaryMarshaler,
+// it will be used.
+// Otherwise it falls back to encoding/binary with LittleEndian.
+// nil payload appends nothing.
+func AppendPayload(b []byte, payload interface{}) ([]byte, error) {
+	switch p := payload.(type) {
+	case nil:
+		return b, nil
+	case BinaryAppender:
+		return p.AppendBinary(b)
+	case encoding.BinaryMarshaler:
+		data, err := p.MarshalBinary()
+		if err != nil {
+			return b, err
+		}
+		return append(b, data...), nil
+	}
+
+	buf := bytes.NewBuffer(b)
+	if err := binary.Write(buf, binary.LittleEndian, payload); err != nil {
+		return b, err
+	}
+	return buf.Bytes(), nil
+}
+
+// grow extends b by n zero bytes,
+// and returns the extended slice and the newly added n bytes.
+func grow(b []byte, n int) (all, added []byte) {
+	l := len(b)
+	all = append(b, make([]byte, n)...)
+	return all, all[l:]
+}
@@ -0,0 +1,356 @@
+package lifxlan_test
+
+import (
+	"bytes"
+	"context"
+	"encoding"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+
+	"go.yhsif.com/lifxlan"
+	"go.yhsif.com/lifxlan/light"
+	"go.yhsif.com/lifxlan/relay"
+	"go.yhsif.com/lifxlan/tile"
+)
+
+type binaryCodec interface {
+	lifxlan.BinaryAppender
+	encoding.BinaryMarshaler
+	encoding.BinaryUnmarshaler
+}
+
+// allCodecs returns new values of all the types with hand-written codecs.
+func allCodecs() []binaryCodec {
+	return []binaryCodec{
+		new(lifxlan.RawHeader),
+		new(lifxlan.Color),
+		new(lifxlan.HardwareVersion),
+		new(lifxlan.RawStateServicePayload),
+		new(lifxlan.RawStateHostFirmwarePayload),
+		new(lifxlan.RawStatePowerPayload),
+		new(lifxlan.RawSetPowerPayload),
+		new(lifxlan.RawSetLabelPayload),
+		new(lifxlan.RawStateLabelPayload),
+		new(lifxlan.RawStateVersionPayload),
+		new(lifxlan.Membership),
+		new(lifxlan.RawStateLocationPayload),
+		new(lifxlan.RawSetLocationPayload),
+		new(lifxlan.RawStateGroupPayload),
+		new(lifxlan.RawSetGroupPayload),
+		new(lifxlan.RawEchoRequestPayload),
+		new(lifxlan.RawEchoResponsePayload),
+		new(lifxlan.RawStateUnhandledPayload),
+		new(light.RawSetColorPayload),
+		new(light.RawStatePayload),
+		new(light.RawSetLightPowerPayload),
+		new(light.RawStateLightPowerPayload),
+		new(light.RawSetWaveformOptionalPayload),
+		new(relay.RawGetRPowerPayload),
+		new(relay.RawStateRPowerPayload),
+		new(relay.RawSetRPowerPayload),
+		new(tile.RawTileDevice),
+		new(tile.RawSetTileState64Payload),
+		new(tile.RawGetTileState64Payload),
+		new(tile.RawStateTileState64Payload),
+		new(tile.RawStateDeviceChainPayload),
+	}
+}
+
+func TestBinaryCodecs(t *testing.T) {
+	const n = 100
+
+	r := rand.New(rand.NewSource(time.Now().UnixNano()))
+	for _, v := range allCodecs() {
+		typ := reflect.TypeOf(v).Elem()
+		t.Run(
+			typ.String(),
+			func(t *testing.T) {
+				size := binary.Size(v)
+				for i := 0; i < n; i++ {
+					random := make([]byte, size)
+					r.Read(random)
+
+					// Normalize the random data with encoding/binary,
+					// which zeros the reserved bytes and quiets signaling NaNs.
+					expected := reflect.New(typ).Interface()
+					if err := binary.Read(
+						bytes.NewReader(random),
+						binary.LittleEndian,
+						expected,
+					); err != nil {
+						t.Fatal(err)
+					}
+					buf := new(bytes.Buffer)
+					if err := binary.Write(buf, binary.LittleEndian, expected); err != nil {
+						t.Fatal(err)
+					}
+					data := buf.Bytes()
+
+					// Compare the formatted values instead of using reflect.DeepEqual,
+					// as random float values could be NaN.
+					actual := reflect.New(typ).Interface().(binaryCodec)
+					if err := actual.UnmarshalBinary(data); err != nil {
+						t.Fatal(err)
+					}
+					if e, a := fmt.Sprintf("%+v", expected), fmt.Sprintf("%+v", actual); e != a {
+						t.Fatalf(
+							"UnmarshalBinary(% x) expected %s, got %s",
+							data,
+							e,
+							a,
+						)
+					}
+
+					marshaled, err := actual.MarshalBinary()
+					if err != nil {
+						t.Fatal(err)
+					}
+					if !bytes.Equal(marshaled, data) {
+						t.Fatalf(
+							"MarshalBinary(%+v) expected % x, got % x",
+							actual,
+							data,
+							marshaled,
+						)
+					}
+
+					prefix := []byte("prefix")
+					appended, err := actual.AppendBinary(prefix)
+					if err != nil {
+						t.Fatal(err)
+					}
+					if !bytes.Equal(appended[len(prefix):], data) ||
+						!bytes.Equal(appended[:len(prefix)], prefix) {
+						t.Fatalf(
+							"AppendBinary(%q) expected %q + % x, got % x",
+							prefix,
+							prefix,
+							data,
+							appended,
+						)
+					}
+
+					if err := actual.UnmarshalBinary(data[:size-1]); err != io.ErrUnexpectedEOF {
+						t.Fatalf(
+							"UnmarshalBinary on short data expected %v, got %v",
+							io.ErrUnexpectedEOF,
+							err,
+						)
+					}
+				}
+			},
+		)
+	}
+}
+
+func TestAppendPayload(t *testing.T) {
+	type payload struct {
+		Foo uint32
+		Bar uint16
+	}
+
+	for _, c := range []struct {
+		label    string
+		payload  interface{}
+		expected []byte
+	}{
+		{
+			label:    "Nil",
+			payload:  nil,
+			expected: []byte{},
+		},
+		{
+			label: "BinaryAppender",
+			payload: &lifxlan.RawSetPowerPayload{
+				Level: lifxlan.PowerOn,
+			},
+			expected: []byte{0xff, 0xff},
+		},
+		{
+			label: "Reflection",
+			payload: &payload{
+				Foo: 1,
+				Bar: 2,
+			},
+			expected: []byte{1, 0, 0, 0, 2, 0},
+		},
+	} {
+		t.Run(
+			c.label,
+			func(t *testing.T) {
+				actual, err := lifxlan.AppendPayload([]byte{}, c.payload)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !bytes.Equal(actual, c.expected) {
+					t.Errorf("Expected % x, got % x", c.expected, actual)
+				}
+			},
+		)
+	}
+}
+
+func TestAppendBinaryAllocs(t *testing.T) {
+	var payload tile.RawSetTileState64Payload
+	buf := make([]byte, 0, lifxlan.ResponseReadBufferSize)
+	allocs := testing.AllocsPerRun(100, func() {
+		if _, err := payload.AppendBinary(buf); err != nil {
+			t.Fatal(err)
+		}
+	})
+	if allocs != 0 {
+		t.Errorf("Expected 0 allocs, got %v", allocs)
+	}
+}
+
+func BenchmarkSetTileState64Payload(b *testing.B) {
+	var payload tile.RawSetTileState64Payload
+	for i := range payload.Colors {
+		payload.Colors[i] = lifxlan.Color{
+			Hue:        uint16(i),
+			Saturation: uint16(i),
+			Brightness: uint16(i),
+			Kelvin:     lifxlan.KelvinWarm,
+		}
+	}
+
+	b.Run(
+		"AppendBinary",
+		func(b *testing.B) {
+			b.ReportAllocs()
+			buf := make([]byte, 0, lifxlan.ResponseReadBufferSize)
+			for i := 0; i < b.N; i++ {
+				if _, err := payload.AppendBinary(buf); err != nil {
+					b.Fatal(err)
+				}
+			}
+		},
+	)
+
+	b.Run(
+		"encoding/binary",
+		func(b *testing.B) {
+			b.ReportAllocs()
+			buf := new(bytes.Buffer)
+			for i := 0; i < b.N; i++ {
+				buf.Reset()
+				if err := binary.Write(buf, binary.LittleEndian, &payload); err != nil {
+					b.Fatal(err)
+				}
+			}
+		},
+	)
+
+	data, err := payload.MarshalBinary()
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	b.Run(
+		"UnmarshalBinary",
+		func(b *testing.B) {
+			b.ReportAllocs()
+			var p tile.RawSetTileState64Payload
+			for i := 0; i < b.N; i++ {
+				if err := p.UnmarshalBinary(data); err != nil {
+					b.Fatal(err)
+				}
+			}
+		},
+	)
+
+	b.Run(
+		"binary.Read",
+		func(b *testing.B) {
+			b.ReportAllocs()
+			var p tile.RawSetTileState64Payload
+			for i := 0; i < b.N; i++ {
+				if err := binary.Read(
+					bytes.NewReader(data),
+					binary.LittleEndian,
+					&p,
+				); err != nil {
+					b.Fatal(err)
+				}
+			}
+		},
+	)
+}
+
+// benchConn is a net.Conn that discards all writes,
+// and returns msg on all reads.
+type benchConn struct {
+	net.Conn
+
+	msg []byte
+}
+
+func (c benchConn) Write(b []byte) (int, error) {
+	return len(b), nil
+}
+
+func (c benchConn) Read(b []byte) (int, error) {
+	return copy(b, c.msg), nil
+}
+
+func (c benchConn) SetReadDeadline(time.Time) error {
+	return nil
+}
+
+func BenchmarkSend(b *testing.B) {
+	device := lifxlan.NewDevice("", lifxlan.ServiceUDP, lifxlan.Target(1))
+	ctx := context.Background()
+	payload := new(tile.RawSetTileState64Payload)
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := device.Send(
+			ctx,
+			benchConn{},
+			0, // flags
+			tile.SetTileState64,
+			payload,
+		); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkReadNextResponse(b *testing.B) {
+	payload, err := new(tile.RawStateTileState64Payload).MarshalBinary()
+	if err != nil {
+		b.Fatal(err)
+	}
+	msg, err := lifxlan.GenerateMessage(
+		lifxlan.NotTagged,
+		lifxlan.RandomSource(),
+		lifxlan.Target(1),
+		0, // flags
+		0, // sequence
+		tile.StateTileState64,
+		payload,
+	)
+	if err != nil {
+		b.Fatal(err)
+	}
+	conn := benchConn{msg: msg}
+	ctx := context.Background()
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		resp, err := lifxlan.ReadNextResponse(ctx, conn)
+		if err != nil {
+			b.Fatal(err)
+		}
+		var raw tile.RawStateTileState64Payload
+		if err := resp.DecodePayload(&raw); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
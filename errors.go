@@ -0,0 +1,114 @@
+package lifxlan
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Sentinel errors returned (usually wrapped) by this package.
+//
+// Use errors.Is to check against them.
+var (
+	// ErrShortWrite means that fewer bytes than the full message were written to
+	// the connection.
+	ErrShortWrite = errors.New("lifxlan: short write")
+
+	// ErrSizeMismatch means that the size of the received message doesn't
+	// match the size in its header,
+	// or it's not even big enough for the header.
+	ErrSizeMismatch = errors.New("lifxlan: size mismatch")
+
+	// ErrEchoMismatch means that the echo response doesn't match the payload
+	// sent by Device.Echo.
+	ErrEchoMismatch = errors.New("lifxlan: unexpected echo response value")
+
+	// ErrInvalidProtocol means that the protocol number in the header is not
+	// ProtocolNumber.
+	ErrInvalidProtocol = errors.New("lifxlan: invalid protocol number")
+
+	// ErrNotAddressable means that the addressable bit in the header is not
+	// set.
+	ErrNotAddressable = errors.New("lifxlan: addressable bit not set")
+
+	// ErrInvalidOrigin means that the origin bits in the header are not 0.
+	ErrInvalidOrigin = errors.New("lifxlan: invalid origin")
+
+	// ErrLabelTruncated means that the label is longer than LabelLength bytes
+	// and was truncated.
+	ErrLabelTruncated = errors.New("lifxlan: label truncated")
+
+	// ErrUnknownService means that the device's ServiceType is not supported.
+	ErrUnknownService = errors.New("lifxlan: unknown service type")
+
+	// ErrDeviceNotFound means that Device.Refresh didn't get a reply from the
+	// device's target before the context deadline.
+	ErrDeviceNotFound = errors.New("lifxlan: device not found")
+
+	// ErrClientConnClosed is returned when using a connection dialed from
+	// Client after it's closed.
+	ErrClientConnClosed = errors.New("lifxlan.Client: use of closed connection")
+
+	// ErrConnPoolClosed is returned when dialing from a ConnPool after it's
+	// closed, or using a connection dialed from ConnPool after it's closed.
+	ErrConnPoolClosed = errors.New("lifxlan.ConnPool: use of closed pool or connection")
+)
+
+// HeaderError is the error returned when a received message has an invalid
+// header.
+//
+// It wraps one of ErrSizeMismatch, ErrInvalidProtocol, ErrNotAddressable,
+// and ErrInvalidOrigin.
+type HeaderError struct {
+	// The invalid header.
+	Header RawHeader
+
+	Err error
+}
+
+var _ error = (*HeaderError)(nil)
+
+func (e *HeaderError) Error() string {
+	return fmt.Sprintf("lifxlan: invalid header: %v", e.Err)
+}
+
+// Unwrap returns the underlying error.
+func (e *HeaderError) Unwrap() error {
+	return e.Err
+}
+
+// UnhandledError is the error returned when the device responds with
+// StateUnhandled, which usually means that the device doesn't support the
+// message.
+type UnhandledError struct {
+	// The target of the device responded.
+	Target Target
+
+	// The type of the original message unhandled by the device.
+	Message MessageType
+
+	// The source and sequence of the StateUnhandled response,
+	// which match the original message.
+	Source   uint32
+	Sequence uint8
+}
+
+var _ error = (*UnhandledError)(nil)
+
+func (e *UnhandledError) Error() string {
+	return fmt.Sprintf(
+		"lifxlan: message %v unhandled by device %v",
+		e.Message,
+		e.Target,
+	)
+}
+
+// As supports errors.As with RawStateUnhandledPayload as target,
+// which was the error type used for StateUnhandled responses in previous
+// versions.
+func (e *UnhandledError) As(target interface{}) bool {
+	if raw, ok := target.(*RawStateUnhandledPayload); ok {
+		raw.UnhandledType = e.Message
+		return true
+	}
+	return false
+}
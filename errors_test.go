@@ -0,0 +1,97 @@
+package lifxlan_test
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+
+	"go.yhsif.com/lifxlan"
+)
+
+// shortConn is a net.Conn that only writes half of the bytes.
+type shortConn struct {
+	net.Conn
+}
+
+func (shortConn) Write(b []byte) (int, error) {
+	return len(b) / 2, nil
+}
+
+func TestErrors(t *testing.T) {
+	t.Run(
+		"ShortWrite",
+		func(t *testing.T) {
+			device := lifxlan.NewDevice("", lifxlan.ServiceUDP, lifxlan.AllDevices)
+			_, err := device.Send(
+				context.Background(),
+				shortConn{},
+				0, // flags
+				lifxlan.GetPower,
+				nil, // payload
+			)
+			if !errors.Is(err, lifxlan.ErrShortWrite) {
+				t.Errorf("Expected ErrShortWrite, got %v", err)
+			}
+		},
+	)
+
+	t.Run(
+		"UnknownService",
+		func(t *testing.T) {
+			device := lifxlan.NewDevice("127.0.0.1:56700", 0, lifxlan.AllDevices)
+			_, err := device.Dial()
+			if !errors.Is(err, lifxlan.ErrUnknownService) {
+				t.Errorf("Expected ErrUnknownService, got %v", err)
+			}
+		},
+	)
+
+	t.Run(
+		"Unhandled",
+		func(t *testing.T) {
+			const target = lifxlan.Target(1)
+			payload, err := (&lifxlan.RawStateUnhandledPayload{
+				UnhandledType: lifxlan.GetLabel,
+			}).MarshalBinary()
+			if err != nil {
+				t.Fatal(err)
+			}
+			msg, err := lifxlan.GenerateMessage(
+				lifxlan.NotTagged,
+				2, // source
+				target,
+				0, // flags
+				3, // sequence
+				lifxlan.StateUnhandled,
+				payload,
+			)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			_, err = lifxlan.ParseResponse(msg)
+			var unhandled *lifxlan.UnhandledError
+			if !errors.As(err, &unhandled) {
+				t.Fatalf("Expected *UnhandledError, got %v", err)
+			}
+			expected := lifxlan.UnhandledError{
+				Target:   target,
+				Message:  lifxlan.GetLabel,
+				Source:   2,
+				Sequence: 3,
+			}
+			if *unhandled != expected {
+				t.Errorf("Expected %+v, got %+v", expected, *unhandled)
+			}
+
+			resp := lifxlan.ErrorResponse(err)
+			if resp == nil {
+				t.Fatal("Expected ErrorResponse, got nil")
+			}
+			if resp.Message != lifxlan.StateUnhandled || resp.Source != 2 || resp.Sequence != 3 {
+				t.Errorf("Unexpected ErrorResponse %+v", resp)
+			}
+		},
+	)
+}
@@ -1,9 +1,9 @@
 package lifxlan
 
 import (
-	"bytes"
 	"context"
 	"encoding/binary"
+	"io"
 	"net"
 )
 
@@ -18,6 +18,32 @@ type RawStateHostFirmwarePayload struct {
 	VersionMajor uint16
 }
 
+// HostFirmwareLength is the length of the encoded RawStateHostFirmwarePayload.
+const HostFirmwareLength = 20
+
+// MarshalBinary implements encoding.BinaryMarshaler.
+func (raw *RawStateHostFirmwarePayload) MarshalBinary() ([]byte, error) {
+	return raw.AppendBinary(make([]byte, 0, HostFirmwareLength))
+}
+
+// AppendBinary implements BinaryAppender.
+func (raw *RawStateHostFirmwarePayload) AppendBinary(b []byte) ([]byte, error) {
+	b, buf := grow(b, HostFirmwareLength)
+	binary.LittleEndian.PutUint16(buf[16:], raw.VersionMinor)
+	binary.LittleEndian.PutUint16(buf[18:], raw.VersionMajor)
+	return b, nil
+}
+
+// UnmarshalBinary implements encoding.BinaryUnmarshaler.
+func (raw *RawStateHostFirmwarePayload) UnmarshalBinary(data []byte) error {
+	if len(data) < HostFirmwareLength {
+		return io.ErrUnexpectedEOF
+	}
+	raw.VersionMinor = binary.LittleEndian.Uint16(data[16:])
+	raw.VersionMajor = binary.LittleEndian.Uint16(data[18:])
+	return nil
+}
+
 // ToFirmware converts RawStateHostFirmwarePayload into FirmwareUpgrade
 // with empty Features.
 func (raw RawStateHostFirmwarePayload) ToFirmware() FirmwareUpgrade {
@@ -32,53 +58,18 @@ func (d *device) Firmware() *FirmwareUpgrade {
 }
 
 func (d *device) GetFirmware(ctx context.Context, conn net.Conn) error {
-	if ctx.Err() != nil {
-		return ctx.Err()
-	}
-
-	if conn == nil {
-		newConn, err := d.Dial()
-		if err != nil {
-			return err
-		}
-		defer newConn.Close()
-		conn = newConn
-
-		if ctx.Err() != nil {
-			return ctx.Err()
-		}
-	}
-
-	seq, err := d.Send(
+	var raw RawStateHostFirmwarePayload
+	if err := Request(
 		ctx,
+		d,
 		conn,
-		0, // flags
 		GetHostFirmware,
 		nil, // payload
-	)
-	if err != nil {
+		StateHostFirmware,
+		&raw,
+	); err != nil {
 		return err
 	}
-
-	for {
-		resp, err := ReadNextResponse(ctx, conn)
-		if err != nil {
-			return err
-		}
-		if resp.Sequence != seq || resp.Source != d.Source() {
-			continue
-		}
-		if resp.Message != StateHostFirmware {
-			continue
-		}
-
-		var raw RawStateHostFirmwarePayload
-		r := bytes.NewReader(resp.Payload)
-		if err := binary.Read(r, binary.LittleEndian, &raw); err != nil {
-			return err
-		}
-
-		d.firmware = raw.ToFirmware()
-		return nil
-	}
+	d.firmware = raw.ToFirmware()
+	return nil
 }
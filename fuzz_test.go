@@ -0,0 +1,97 @@
+//go:build go1.18
+// +build go1.18
+
+package lifxlan_test
+
+import (
+	"bytes"
+	"testing"
+
+	"go.yhsif.com/lifxlan"
+)
+
+func FuzzParseResponse(f *testing.F) {
+	for _, c := range []struct {
+		tagged  lifxlan.TaggedHeader
+		flags   lifxlan.AckResFlag
+		message lifxlan.MessageType
+		payload []byte
+	}{
+		{
+			tagged:  lifxlan.Tagged,
+			message: lifxlan.GetService,
+		},
+		{
+			tagged:  lifxlan.NotTagged,
+			flags:   lifxlan.FlagAckRequired,
+			message: lifxlan.StatePower,
+			payload: []byte{0xff, 0xff},
+		},
+		{
+			tagged:  lifxlan.NotTagged,
+			message: lifxlan.StateUnhandled,
+			payload: []byte{23, 0},
+		},
+	} {
+		msg, err := lifxlan.GenerateMessage(
+			c.tagged,
+			lifxlan.RandomSource(),
+			lifxlan.Target(1),
+			c.flags,
+			0, // sequence
+			c.message,
+			c.payload,
+		)
+		if err != nil {
+			f.Fatal(err)
+		}
+		f.Add(msg)
+	}
+	f.Add([]byte{})
+
+	f.Fuzz(func(t *testing.T, msg []byte) {
+		resp, err := lifxlan.ParseResponse(msg)
+		if err != nil {
+			return
+		}
+		if len(resp.Payload)+lifxlan.HeaderLength != len(msg) {
+			t.Fatalf(
+				"Expected payload size %d, got %d",
+				len(msg)-lifxlan.HeaderLength,
+				len(resp.Payload),
+			)
+		}
+
+		tagged := lifxlan.NotTagged
+		if resp.Tagged {
+			tagged = lifxlan.Tagged
+		}
+		generated, err := lifxlan.GenerateMessage(
+			tagged,
+			resp.Source,
+			resp.Target,
+			resp.Flags,
+			resp.Sequence,
+			resp.Message,
+			resp.Payload,
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// Reserved bytes are ignored by ParseResponse, so compare the parsed
+		// results instead.
+		parsed, err := lifxlan.ParseResponse(generated)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if parsed.Tagged != resp.Tagged ||
+			parsed.Source != resp.Source ||
+			parsed.Target != resp.Target ||
+			parsed.Flags != resp.Flags ||
+			parsed.Sequence != resp.Sequence ||
+			parsed.Message != resp.Message ||
+			!bytes.Equal(parsed.Payload, resp.Payload) {
+			t.Errorf("Round trip expected %+v, got %+v", resp, parsed)
+		}
+	})
+}
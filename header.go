@@ -1,8 +1,9 @@
 package lifxlan
 
 import (
-	"bytes"
 	"encoding/binary"
+	"fmt"
+	"io"
 	"math"
 	"math/rand"
 	"time"
@@ -31,6 +32,55 @@ const (
 	Tagged    TaggedHeader = 1<<13 + NotTagged
 )
 
+// ProtocolNumber is the only valid protocol number in TaggedHeader.
+const ProtocolNumber = 1024
+
+// Masks of the fields in TaggedHeader.
+const (
+	protocolMask    TaggedHeader = 1<<12 - 1
+	addressableMask TaggedHeader = 1 << 12
+	taggedMask      TaggedHeader = 1 << 13
+	originMask      TaggedHeader = 3 << 14
+)
+
+// Protocol returns the protocol number, which must be 1024.
+func (t TaggedHeader) Protocol() uint16 {
+	return uint16(t & protocolMask)
+}
+
+// Addressable returns whether the addressable bit is set,
+// which must be true.
+func (t TaggedHeader) Addressable() bool {
+	return t&addressableMask != 0
+}
+
+// IsTagged returns whether the tagged bit is set.
+func (t TaggedHeader) IsTagged() bool {
+	return t&taggedMask != 0
+}
+
+// Origin returns the origin bits, which must be 0.
+func (t TaggedHeader) Origin() uint8 {
+	return uint8((t & originMask) >> 14)
+}
+
+// Validate checks all the invariants of TaggedHeader.
+//
+// The returned error wraps one of ErrInvalidProtocol, ErrNotAddressable,
+// and ErrInvalidOrigin.
+func (t TaggedHeader) Validate() error {
+	if p := t.Protocol(); p != ProtocolNumber {
+		return fmt.Errorf("%w: %d", ErrInvalidProtocol, p)
+	}
+	if !t.Addressable() {
+		return ErrNotAddressable
+	}
+	if o := t.Origin(); o != 0 {
+		return fmt.Errorf("%w: %d", ErrInvalidOrigin, o)
+	}
+	return nil
+}
+
 // AckResFlag is the 8-bit header that could include:
 //
 // - ack_required: if set all sent messages will expect an ack response.
@@ -44,6 +94,16 @@ const (
 	FlagAckRequired
 )
 
+// AckRequired returns whether the ack_required bit is set.
+func (f AckResFlag) AckRequired() bool {
+	return f&FlagAckRequired != 0
+}
+
+// ResRequired returns whether the res_required bit is set.
+func (f AckResFlag) ResRequired() bool {
+	return f&FlagResRequired != 0
+}
+
 // RawHeader defines the struct to be used for encoding and decoding.
 //
 // https://lan.developer.lifx.com/docs/packet-contents#header
@@ -63,7 +123,64 @@ type RawHeader struct {
 // HeaderLength is the length of the header
 const HeaderLength = 36
 
+// MarshalBinary implements encoding.BinaryMarshaler.
+func (h *RawHeader) MarshalBinary() ([]byte, error) {
+	return h.AppendBinary(make([]byte, 0, HeaderLength))
+}
+
+// AppendBinary implements BinaryAppender.
+func (h *RawHeader) AppendBinary(b []byte) ([]byte, error) {
+	b, buf := grow(b, HeaderLength)
+	binary.LittleEndian.PutUint16(buf[0:], h.Size)
+	binary.LittleEndian.PutUint16(buf[2:], uint16(h.Tagged))
+	binary.LittleEndian.PutUint32(buf[4:], h.Source)
+	binary.LittleEndian.PutUint64(buf[8:], uint64(h.Target))
+	buf[22] = uint8(h.Flags)
+	buf[23] = h.Sequence
+	binary.LittleEndian.PutUint16(buf[32:], uint16(h.Type))
+	return b, nil
+}
+
+// Validate checks all the documented invariants of the header,
+// except that the Size matches the actual size of the message.
+//
+// If it returns an error, the error is of type *HeaderError.
+func (h *RawHeader) Validate() error {
+	if h.Size < HeaderLength {
+		return &HeaderError{
+			Header: *h,
+			Err:    fmt.Errorf("%w: %d < %d", ErrSizeMismatch, h.Size, HeaderLength),
+		}
+	}
+	if err := h.Tagged.Validate(); err != nil {
+		return &HeaderError{
+			Header: *h,
+			Err:    err,
+		}
+	}
+	return nil
+}
+
+// UnmarshalBinary implements encoding.BinaryUnmarshaler.
+//
+// It does not validate the header, use Validate for that.
+func (h *RawHeader) UnmarshalBinary(data []byte) error {
+	if len(data) < HeaderLength {
+		return io.ErrUnexpectedEOF
+	}
+	h.Size = binary.LittleEndian.Uint16(data[0:])
+	h.Tagged = TaggedHeader(binary.LittleEndian.Uint16(data[2:]))
+	h.Source = binary.LittleEndian.Uint32(data[4:])
+	h.Target = Target(binary.LittleEndian.Uint64(data[8:]))
+	h.Flags = AckResFlag(data[22])
+	h.Sequence = data[23]
+	h.Type = MessageType(binary.LittleEndian.Uint16(data[32:]))
+	return nil
+}
+
 // ResponseReadBufferSize is the recommended buffer size to read UDP responses.
+//
+// It's the default value of Config.ReadBufferSize.
 // It's big enough for all the payloads.
 const ResponseReadBufferSize = 4096
 
@@ -78,7 +195,6 @@ func GenerateMessage(
 	payload []byte,
 ) ([]byte, error) {
 	var size = HeaderLength + uint16(len(payload))
-	buf := new(bytes.Buffer)
 	data := &RawHeader{
 		Size:     size,
 		Tagged:   tagged,
@@ -88,13 +204,11 @@ func GenerateMessage(
 		Sequence: sequence,
 		Type:     message,
 	}
-	if err := binary.Write(buf, binary.LittleEndian, data); err != nil {
-		return nil, err
-	}
-	if _, err := buf.Write(payload); err != nil {
+	buf, err := data.AppendBinary(make([]byte, 0, int(size)))
+	if err != nil {
 		return nil, err
 	}
-	return buf.Bytes(), nil
+	return append(buf, payload...), nil
 }
 
 var maxSource int64 = math.MaxUint32
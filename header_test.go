@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"crypto/rand"
 	"encoding/binary"
+	"errors"
 	"testing"
 	"testing/quick"
 
@@ -25,8 +26,8 @@ func TestParseResponseError(t *testing.T) {
 				func(t *testing.T) {
 					var buf []byte
 					_, err := lifxlan.ParseResponse(buf)
-					if err == nil {
-						t.Errorf("Expected size not enough error for msg % x", buf)
+					if !errors.Is(err, lifxlan.ErrSizeMismatch) {
+						t.Errorf("Expected size not enough error for msg % x, got %v", buf, err)
 					}
 				},
 			)
@@ -37,8 +38,8 @@ func TestParseResponseError(t *testing.T) {
 					size := lifxlan.HeaderLength - 1
 					buf := makeMsg(size)
 					_, err := lifxlan.ParseResponse(buf)
-					if err == nil {
-						t.Errorf("Expected size not enough error for msg % x", buf)
+					if !errors.Is(err, lifxlan.ErrSizeMismatch) {
+						t.Errorf("Expected size not enough error for msg % x, got %v", buf, err)
 					}
 				},
 			)
@@ -51,11 +52,103 @@ func TestParseResponseError(t *testing.T) {
 			size := lifxlan.HeaderLength + 10
 			buf := makeMsg(size)[:lifxlan.HeaderLength+1]
 			_, err := lifxlan.ParseResponse(buf)
-			if err == nil {
-				t.Errorf("Expected size mismatch error for msg % x", buf)
+			if !errors.Is(err, lifxlan.ErrSizeMismatch) {
+				t.Errorf("Expected size mismatch error for msg % x, got %v", buf, err)
 			}
 		},
 	)
+
+	for _, c := range []struct {
+		label    string
+		tagged   lifxlan.TaggedHeader
+		expected error
+	}{
+		{
+			label:    "InvalidProtocol",
+			tagged:   lifxlan.NotTagged + 1,
+			expected: lifxlan.ErrInvalidProtocol,
+		},
+		{
+			label:    "NotAddressable",
+			tagged:   lifxlan.NotTagged &^ (1 << 12),
+			expected: lifxlan.ErrNotAddressable,
+		},
+		{
+			label:    "InvalidOrigin",
+			tagged:   lifxlan.Tagged | 1<<15,
+			expected: lifxlan.ErrInvalidOrigin,
+		},
+	} {
+		t.Run(
+			c.label,
+			func(t *testing.T) {
+				msg, err := lifxlan.GenerateMessage(
+					c.tagged,
+					0, // source
+					lifxlan.AllDevices,
+					0, // flags
+					0, // sequence
+					lifxlan.StatePower,
+					nil, // payload
+				)
+				if err != nil {
+					t.Fatal(err)
+				}
+				_, err = lifxlan.ParseResponse(msg)
+				if !errors.Is(err, c.expected) {
+					t.Errorf("Expected %v for msg % x, got %v", c.expected, msg, err)
+				}
+				var headerErr *lifxlan.HeaderError
+				if !errors.As(err, &headerErr) {
+					t.Fatalf("Expected *HeaderError, got %v", err)
+				}
+				if headerErr.Header.Tagged != c.tagged {
+					t.Errorf(
+						"Expected header.Tagged %x, got %x",
+						c.tagged,
+						headerErr.Header.Tagged,
+					)
+				}
+			},
+		)
+	}
+}
+
+func TestTaggedHeader(t *testing.T) {
+	for _, c := range []struct {
+		tagged   lifxlan.TaggedHeader
+		isTagged bool
+	}{
+		{
+			tagged:   lifxlan.NotTagged,
+			isTagged: false,
+		},
+		{
+			tagged:   lifxlan.Tagged,
+			isTagged: true,
+		},
+	} {
+		if err := c.tagged.Validate(); err != nil {
+			t.Errorf("%x.Validate() expected nil, got %v", c.tagged, err)
+		}
+		if c.tagged.Protocol() != lifxlan.ProtocolNumber {
+			t.Errorf(
+				"%x.Protocol() expected %d, got %d",
+				c.tagged,
+				lifxlan.ProtocolNumber,
+				c.tagged.Protocol(),
+			)
+		}
+		if !c.tagged.Addressable() {
+			t.Errorf("%x.Addressable() expected true", c.tagged)
+		}
+		if c.tagged.Origin() != 0 {
+			t.Errorf("%x.Origin() expected 0, got %d", c.tagged, c.tagged.Origin())
+		}
+		if c.tagged.IsTagged() != c.isTagged {
+			t.Errorf("%x.IsTagged() expected %v", c.tagged, c.isTagged)
+		}
+	}
 }
 
 func TestHeader(t *testing.T) {
@@ -93,6 +186,9 @@ func TestHeader(t *testing.T) {
 			if resp.Source != 0 {
 				t.Errorf("resp.Source expected 0, got %v", resp.Source)
 			}
+			if !resp.Tagged {
+				t.Error("resp.Tagged expected true")
+			}
 			if resp.Target != lifxlan.AllDevices {
 				t.Errorf(
 					"resp.Target expected %v, got %v",
@@ -149,6 +245,9 @@ func TestHeader(t *testing.T) {
 			if resp.Source != source {
 				t.Errorf("resp.Source expected %v, got %v", source, resp.Source)
 			}
+			if resp.Tagged {
+				t.Error("resp.Tagged expected false")
+			}
 			if resp.Target != target {
 				t.Errorf(
 					"resp.Target expected %v, got %v",
@@ -164,6 +263,63 @@ func TestHeader(t *testing.T) {
 			}
 		},
 	)
+
+	t.Run(
+		"Flags",
+		func(t *testing.T) {
+			for _, c := range []struct {
+				flags lifxlan.AckResFlag
+				ack   bool
+				res   bool
+			}{
+				{
+					flags: 0,
+				},
+				{
+					flags: lifxlan.FlagAckRequired,
+					ack:   true,
+				},
+				{
+					flags: lifxlan.FlagResRequired,
+					res:   true,
+				},
+				{
+					flags: lifxlan.FlagAckRequired | lifxlan.FlagResRequired,
+					ack:   true,
+					res:   true,
+				},
+			} {
+				sequence++
+
+				msg, err := lifxlan.GenerateMessage(
+					lifxlan.NotTagged,
+					0, // source
+					lifxlan.AllDevices,
+					c.flags,
+					sequence,
+					lifxlan.GetService,
+					nil, // payload
+				)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				resp, err := lifxlan.ParseResponse(msg)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if resp.Flags != c.flags {
+					t.Errorf("resp.Flags expected %v, got %v", c.flags, resp.Flags)
+				}
+				if resp.AckRequired() != c.ack {
+					t.Errorf("%v: resp.AckRequired() expected %v", c.flags, c.ack)
+				}
+				if resp.ResRequired() != c.res {
+					t.Errorf("%v: resp.ResRequired() expected %v", c.flags, c.res)
+				}
+			}
+		},
+	)
 }
 
 func TestRandomSource(t *testing.T) {
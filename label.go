@@ -3,9 +3,11 @@ package lifxlan
 import (
 	"bytes"
 	"context"
-	"encoding/binary"
 	"flag"
+	"fmt"
+	"io"
 	"net"
+	"unicode/utf8"
 )
 
 // EmptyLabel is the constant to be compared against Device.Label().String().
@@ -22,6 +24,51 @@ type RawStateLabelPayload struct {
 	Label Label
 }
 
+// MarshalBinary implements encoding.BinaryMarshaler.
+func (p *RawStateLabelPayload) MarshalBinary() ([]byte, error) {
+	return p.AppendBinary(make([]byte, 0, LabelLength))
+}
+
+// AppendBinary implements BinaryAppender.
+func (p *RawStateLabelPayload) AppendBinary(b []byte) ([]byte, error) {
+	return append(b, p.Label[:]...), nil
+}
+
+// UnmarshalBinary implements encoding.BinaryUnmarshaler.
+func (p *RawStateLabelPayload) UnmarshalBinary(data []byte) error {
+	if len(data) < LabelLength {
+		return io.ErrUnexpectedEOF
+	}
+	copy(p.Label[:], data)
+	return nil
+}
+
+// RawSetLabelPayload defines the struct to be used for encoding and decoding.
+//
+// https://lan.developer.lifx.com/docs/changing-a-device#setlabel---packet-24
+type RawSetLabelPayload struct {
+	Label Label
+}
+
+// MarshalBinary implements encoding.BinaryMarshaler.
+func (p *RawSetLabelPayload) MarshalBinary() ([]byte, error) {
+	return p.AppendBinary(make([]byte, 0, LabelLength))
+}
+
+// AppendBinary implements BinaryAppender.
+func (p *RawSetLabelPayload) AppendBinary(b []byte) ([]byte, error) {
+	return append(b, p.Label[:]...), nil
+}
+
+// UnmarshalBinary implements encoding.BinaryUnmarshaler.
+func (p *RawSetLabelPayload) UnmarshalBinary(data []byte) error {
+	if len(data) < LabelLength {
+		return io.ErrUnexpectedEOF
+	}
+	copy(p.Label[:], data)
+	return nil
+}
+
 // LabelLength is the length of the raw label used in messages.
 const LabelLength = 32
 
@@ -42,15 +89,32 @@ func (l Label) String() string {
 
 // Set encodes label into Label.
 //
-// Long labels will be truncated. This function always return nil error.
+// Labels longer than LabelLength bytes will be truncated on a rune boundary,
+// so that a multi-byte UTF-8 character is never cut in half,
+// and ErrLabelTruncated (wrapped) will be returned along with the truncated
+// label set.
 //
 // It also implements flag.Value interface.
 func (l *Label) Set(label string) error {
 	for i := 0; i < LabelLength; i++ {
 		l[i] = 0
 	}
-	copy((*l)[:], label)
-	return nil
+	if len(label) <= LabelLength {
+		copy((*l)[:], label)
+		return nil
+	}
+
+	n := LabelLength
+	for n > 0 && !utf8.RuneStart(label[n]) {
+		n--
+	}
+	copy((*l)[:], label[:n])
+	return fmt.Errorf(
+		"lifxlan.Label.Set: %w: %d > %d bytes",
+		ErrLabelTruncated,
+		len(label),
+		LabelLength,
+	)
 }
 
 // Get implements flag.Getter interface.
@@ -59,53 +123,42 @@ func (l Label) Get() interface{} {
 }
 
 func (d *device) GetLabel(ctx context.Context, conn net.Conn) error {
-	if ctx.Err() != nil {
-		return ctx.Err()
-	}
-
-	if conn == nil {
-		newConn, err := d.Dial()
-		if err != nil {
-			return err
-		}
-		defer newConn.Close()
-		conn = newConn
-
-		if ctx.Err() != nil {
-			return ctx.Err()
-		}
-	}
-
-	seq, err := d.Send(
+	var raw RawStateLabelPayload
+	if err := Request(
 		ctx,
+		d,
 		conn,
-		0, // flags
 		GetLabel,
 		nil, // payload
-	)
-	if err != nil {
+		StateLabel,
+		&raw,
+	); err != nil {
 		return err
 	}
+	d.label = raw.Label
+	return nil
+}
 
-	for {
-		resp, err := ReadNextResponse(ctx, conn)
-		if err != nil {
-			return err
-		}
-		if resp.Sequence != seq || resp.Source != d.Source() {
-			continue
-		}
-		if resp.Message != StateLabel {
-			continue
-		}
-
-		var raw RawStateLabelPayload
-		r := bytes.NewReader(resp.Payload)
-		if err := binary.Read(r, binary.LittleEndian, &raw); err != nil {
-			return err
-		}
-
-		d.label = raw.Label
-		return nil
+func (d *device) SetLabel(
+	ctx context.Context,
+	conn net.Conn,
+	label string,
+	ack bool,
+) error {
+	var payload RawSetLabelPayload
+	if err := payload.Label.Set(label); err != nil {
+		return err
 	}
+	if err := Command(
+		ctx,
+		d,
+		conn,
+		SetLabel,
+		&payload,
+		ack,
+	); err != nil {
+		return err
+	}
+	d.label = payload.Label
+	return nil
 }
@@ -2,6 +2,7 @@ package lifxlan_test
 
 import (
 	"context"
+	"errors"
 	"testing"
 	"time"
 
@@ -42,41 +43,45 @@ func TestLabel(t *testing.T) {
 		},
 	)
 
-	t.Run(
-		"Long",
-		func(t *testing.T) {
-			label := "0123456789012345678901234567890123456789"
+	for _, c := range []struct {
+		label    string
+		input    string
+		expected string
+	}{
+		{
+			label: "Long",
+			input: "0123456789012345678901234567890123456789",
 			// First 32 bytes in utf8
-			expected := "01234567890123456789012345678901"
-			var rl lifxlan.Label
-			err := rl.Set(label)
-			if err != nil {
-				t.Fatalf("Label.Set returned err: %v", err)
-			}
-			got := rl.String()
-			if got != expected {
-				t.Errorf("Expected %q, got %q", label, got)
-			}
+			expected: "01234567890123456789012345678901",
 		},
-	)
-
-	t.Run(
-		"LongUnicode",
-		func(t *testing.T) {
-			label := "中文6789012345678901234567890123456789"
+		{
+			label: "LongUnicode",
+			input: "中文6789012345678901234567890123456789",
 			// First 32 bytes in utf8
-			expected := "中文67890123456789012345678901"
-			var rl lifxlan.Label
-			err := rl.Set(label)
-			if err != nil {
-				t.Fatalf("Label.Set returned err: %v", err)
-			}
-			got := rl.String()
-			if got != expected {
-				t.Errorf("Expected %q, got %q", label, got)
-			}
+			expected: "中文67890123456789012345678901",
 		},
-	)
+		{
+			label: "SplitRune",
+			input: "0123456789012345678901234567890中文",
+			// 中 would be cut in half at byte 32
+			expected: "0123456789012345678901234567890",
+		},
+	} {
+		t.Run(
+			c.label,
+			func(t *testing.T) {
+				var rl lifxlan.Label
+				err := rl.Set(c.input)
+				if !errors.Is(err, lifxlan.ErrLabelTruncated) {
+					t.Errorf("Expected %v, got %v", lifxlan.ErrLabelTruncated, err)
+				}
+				got := rl.String()
+				if got != c.expected {
+					t.Errorf("Expected %q, got %q", c.expected, got)
+				}
+			},
+		)
+	}
 }
 
 func TestEmptyLabel(t *testing.T) {
@@ -112,3 +117,31 @@ func TestGetLabel(t *testing.T) {
 		t.Errorf("Label expected %v, got %v", expected, device.Label())
 	}
 }
+
+func TestSetLabel(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping test in short mode.")
+	}
+
+	const timeout = time.Millisecond * 200
+
+	_, device := mock.StartService(t)
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	if err := device.SetLabel(ctx, nil, "foo", true); err != nil {
+		t.Fatal(err)
+	}
+	if label := device.Label().String(); label != "foo" {
+		t.Errorf("Label expected %q, got %q", "foo", label)
+	}
+
+	err := device.SetLabel(ctx, nil, "0123456789012345678901234567890123456789", true)
+	if !errors.Is(err, lifxlan.ErrLabelTruncated) {
+		t.Errorf("Expected %v, got %v", lifxlan.ErrLabelTruncated, err)
+	}
+	if label := device.Label().String(); label != "foo" {
+		t.Errorf("Label expected %q to be kept, got %q", "foo", label)
+	}
+}
@@ -1,9 +1,9 @@
 package light
 
 import (
-	"bytes"
 	"context"
 	"encoding/binary"
+	"io"
 	"net"
 	"time"
 
@@ -19,6 +19,38 @@ type RawSetColorPayload struct {
 	Duration lifxlan.TransitionTime
 }
 
+// RawSetColorPayloadLength is the length of the encoded RawSetColorPayload.
+const RawSetColorPayloadLength = 13
+
+// MarshalBinary implements encoding.BinaryMarshaler.
+func (p *RawSetColorPayload) MarshalBinary() ([]byte, error) {
+	return p.AppendBinary(make([]byte, 0, RawSetColorPayloadLength))
+}
+
+// AppendBinary implements lifxlan.BinaryAppender.
+func (p *RawSetColorPayload) AppendBinary(b []byte) ([]byte, error) {
+	b = append(b, 0) // reserved
+	b, err := p.Color.AppendBinary(b)
+	if err != nil {
+		return b, err
+	}
+	var buf [4]byte
+	binary.LittleEndian.PutUint32(buf[:], uint32(p.Duration))
+	return append(b, buf[:]...), nil
+}
+
+// UnmarshalBinary implements encoding.BinaryUnmarshaler.
+func (p *RawSetColorPayload) UnmarshalBinary(data []byte) error {
+	if len(data) < RawSetColorPayloadLength {
+		return io.ErrUnexpectedEOF
+	}
+	if err := p.Color.UnmarshalBinary(data[1:]); err != nil {
+		return err
+	}
+	p.Duration = lifxlan.TransitionTime(binary.LittleEndian.Uint32(data[9:]))
+	return nil
+}
+
 func (ld *device) SetColor(
 	ctx context.Context,
 	conn net.Conn,
@@ -26,47 +58,45 @@ func (ld *device) SetColor(
 	transition time.Duration,
 	ack bool,
 ) error {
-	if ctx.Err() != nil {
-		return ctx.Err()
-	}
-
-	if conn == nil {
-		newConn, err := ld.Dial()
-		if err != nil {
-			return err
-		}
-		defer newConn.Close()
-		conn = newConn
-
-		if ctx.Err() != nil {
-			return ctx.Err()
-		}
-	}
-
-	var flags lifxlan.AckResFlag
-	if ack {
-		flags |= lifxlan.FlagAckRequired
-	}
-
-	// Send
-	seq, err := ld.Send(
+	return lifxlan.Command(
 		ctx,
+		ld,
 		conn,
-		flags,
 		SetColor,
 		&RawSetColorPayload{
 			Color:    ld.SanitizeColor(*color),
 			Duration: lifxlan.ConvertDuration(transition),
 		},
+		ack,
 	)
-	if err != nil {
-		return err
-	}
+}
 
-	if ack {
-		return lifxlan.WaitForAcks(ctx, conn, ld.Source(), seq)
+func (ld *device) SetColorAndGet(
+	ctx context.Context,
+	conn net.Conn,
+	color *lifxlan.Color,
+	transition time.Duration,
+) (*lifxlan.Color, error) {
+	var raw RawStatePayload
+	if err := lifxlan.SetRequest(
+		ctx,
+		ld,
+		conn,
+		SetColor,
+		&RawSetColorPayload{
+			Color:    ld.SanitizeColor(*color),
+			Duration: lifxlan.ConvertDuration(transition),
+		},
+		State,
+		&raw,
+	); err != nil {
+		return nil, err
 	}
-	return nil
+
+	*ld.Label() = raw.Label
+	// Make a copy so we don't pin the whole raw payload from gc.
+	c := raw.Color
+	return &c, nil
 }
 
 // RawStatePayload defines the struct to be used for encoding and decoding.
@@ -80,61 +110,59 @@ type RawStatePayload struct {
 	_     [8]byte // reserved
 }
 
-func (ld *device) GetColor(
-	ctx context.Context,
-	conn net.Conn,
-) (*lifxlan.Color, error) {
-	if ctx.Err() != nil {
-		return nil, ctx.Err()
+// RawStatePayloadLength is the length of the encoded RawStatePayload.
+const RawStatePayloadLength = 52
+
+// MarshalBinary implements encoding.BinaryMarshaler.
+func (p *RawStatePayload) MarshalBinary() ([]byte, error) {
+	return p.AppendBinary(make([]byte, 0, RawStatePayloadLength))
+}
+
+// AppendBinary implements lifxlan.BinaryAppender.
+func (p *RawStatePayload) AppendBinary(b []byte) ([]byte, error) {
+	b, err := p.Color.AppendBinary(b)
+	if err != nil {
+		return b, err
 	}
+	var buf [4]byte
+	binary.LittleEndian.PutUint16(buf[2:], uint16(p.Power))
+	b = append(b, buf[:]...)
+	b = append(b, p.Label[:]...)
+	return append(b, make([]byte, 8)...), nil // reserved
+}
 
-	if conn == nil {
-		newConn, err := ld.Dial()
-		if err != nil {
-			return nil, err
-		}
-		defer newConn.Close()
-		conn = newConn
-
-		if ctx.Err() != nil {
-			return nil, ctx.Err()
-		}
+// UnmarshalBinary implements encoding.BinaryUnmarshaler.
+func (p *RawStatePayload) UnmarshalBinary(data []byte) error {
+	if len(data) < RawStatePayloadLength {
+		return io.ErrUnexpectedEOF
 	}
+	if err := p.Color.UnmarshalBinary(data); err != nil {
+		return err
+	}
+	p.Power = lifxlan.Power(binary.LittleEndian.Uint16(data[10:]))
+	copy(p.Label[:], data[12:])
+	return nil
+}
 
-	// Send
-	seq, err := ld.Send(
+func (ld *device) GetColor(
+	ctx context.Context,
+	conn net.Conn,
+) (*lifxlan.Color, error) {
+	var raw RawStatePayload
+	if err := lifxlan.Request(
 		ctx,
+		ld,
 		conn,
-		0, // flags
 		Get,
 		nil, // payload
-	)
-	if err != nil {
+		State,
+		&raw,
+	); err != nil {
 		return nil, err
 	}
 
-	// Read
-	for {
-		resp, err := lifxlan.ReadNextResponse(ctx, conn)
-		if err != nil {
-			return nil, err
-		}
-		if resp.Sequence != seq || resp.Source != ld.Source() {
-			continue
-		}
-		if resp.Message != State {
-			continue
-		}
-
-		var raw RawStatePayload
-		r := bytes.NewReader(resp.Payload)
-		if err := binary.Read(r, binary.LittleEndian, &raw); err != nil {
-			return nil, err
-		}
-
-		*ld.Label() = raw.Label
-		// Make a copy so we don't pin the whole raw payload from gc.
-		color := raw.Color
-		return &color, nil
-	}
+	*ld.Label() = raw.Label
+	// Make a copy so we don't pin the whole raw payload from gc.
+	color := raw.Color
+	return &color, nil
 }
@@ -153,4 +153,44 @@ func TestSetColor(t *testing.T) {
 			)
 		},
 	)
+
+	t.Run(
+		"SetColorAndGet",
+		func(t *testing.T) {
+			delete(service.Handlers, light.SetColor)
+			*ld.HardwareVersion() = version
+			*ld.Label() = lifxlan.Label{}
+
+			ctx, cancel := context.WithTimeout(context.Background(), timeout)
+			defer cancel()
+
+			ret, err := ld.SetColorAndGet(ctx, nil, &color, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			expected := ld.SanitizeColor(color)
+			if *ret != expected {
+				t.Errorf("Expected sanitized color %+v, got %+v", expected, *ret)
+			}
+			if gotLabel := ld.Label().String(); gotLabel != label.String() {
+				t.Errorf("Expected label %q, got %q", label.String(), gotLabel)
+			}
+		},
+	)
+
+	t.Run(
+		"SetLightPowerAndGet",
+		func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), timeout)
+			defer cancel()
+
+			power, err := ld.SetLightPowerAndGet(ctx, nil, lifxlan.PowerOn, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if power != lifxlan.PowerOn {
+				t.Errorf("Expected power %v, got %v", lifxlan.PowerOn, power)
+			}
+		},
+	)
 }
@@ -35,6 +35,18 @@ type Device interface {
 	// device.
 	SetColor(ctx context.Context, conn net.Conn, color *lifxlan.Color, transition time.Duration, ack bool) error
 
+	// SetColorAndGet is similar to SetColor,
+	// but requests the device to reply with the resulting color,
+	// and returns it.
+	//
+	// The returned color is what the device actually applied,
+	// which could be different from color after sanitization.
+	// The cached label is also updated from the reply.
+	//
+	// The message will be retransmitted according to RetryPolicy() until the
+	// response is received.
+	SetColorAndGet(ctx context.Context, conn net.Conn, color *lifxlan.Color, transition time.Duration) (*lifxlan.Color, error)
+
 	// SetLightPower sets the power level of the device and specifies how long it
 	// will take to transition to the new power state.
 	//
@@ -50,6 +62,14 @@ type Device interface {
 	// device.
 	SetLightPower(ctx context.Context, conn net.Conn, power lifxlan.Power, transition time.Duration, ack bool) error
 
+	// SetLightPowerAndGet is similar to SetLightPower,
+	// but requests the device to reply with the resulting power level,
+	// and returns it.
+	//
+	// The message will be retransmitted according to RetryPolicy() until the
+	// response is received.
+	SetLightPowerAndGet(ctx context.Context, conn net.Conn, power lifxlan.Power, transition time.Duration) (lifxlan.Power, error)
+
 	// SetWaveform sends SetWaveformOptional message as defined in
 	//
 	// https://lan.developer.lifx.com/docs/changing-a-device#setwaveformoptional---packet-119
@@ -10,5 +10,15 @@ const (
 	SetColor            lifxlan.MessageType = 102
 	State               lifxlan.MessageType = 107
 	SetLightPower       lifxlan.MessageType = 117
+	StateLightPower     lifxlan.MessageType = 118
 	SetWaveformOptional lifxlan.MessageType = 119
 )
+
+func init() {
+	lifxlan.RegisterMessage(Get, "light.Get", nil)
+	lifxlan.RegisterMessage(SetColor, "light.SetColor", RawSetColorPayload{})
+	lifxlan.RegisterMessage(State, "light.State", RawStatePayload{})
+	lifxlan.RegisterMessage(SetLightPower, "light.SetLightPower", RawSetLightPowerPayload{})
+	lifxlan.RegisterMessage(StateLightPower, "light.StateLightPower", RawStateLightPowerPayload{})
+	lifxlan.RegisterMessage(SetWaveformOptional, "light.SetWaveformOptional", RawSetWaveformOptionalPayload{})
+}
@@ -2,6 +2,8 @@ package light
 
 import (
 	"context"
+	"encoding/binary"
+	"io"
 	"net"
 	"time"
 
@@ -16,6 +18,33 @@ type RawSetLightPowerPayload struct {
 	Duration lifxlan.TransitionTime
 }
 
+// RawSetLightPowerPayloadLength is the length of the encoded
+// RawSetLightPowerPayload.
+const RawSetLightPowerPayloadLength = 6
+
+// MarshalBinary implements encoding.BinaryMarshaler.
+func (p *RawSetLightPowerPayload) MarshalBinary() ([]byte, error) {
+	return p.AppendBinary(make([]byte, 0, RawSetLightPowerPayloadLength))
+}
+
+// AppendBinary implements lifxlan.BinaryAppender.
+func (p *RawSetLightPowerPayload) AppendBinary(b []byte) ([]byte, error) {
+	var buf [RawSetLightPowerPayloadLength]byte
+	binary.LittleEndian.PutUint16(buf[0:], uint16(p.Level))
+	binary.LittleEndian.PutUint32(buf[2:], uint32(p.Duration))
+	return append(b, buf[:]...), nil
+}
+
+// UnmarshalBinary implements encoding.BinaryUnmarshaler.
+func (p *RawSetLightPowerPayload) UnmarshalBinary(data []byte) error {
+	if len(data) < RawSetLightPowerPayloadLength {
+		return io.ErrUnexpectedEOF
+	}
+	p.Level = lifxlan.Power(binary.LittleEndian.Uint16(data[0:]))
+	p.Duration = lifxlan.TransitionTime(binary.LittleEndian.Uint32(data[2:]))
+	return nil
+}
+
 func (ld *device) SetLightPower(
 	ctx context.Context,
 	conn net.Conn,
@@ -23,45 +52,68 @@ func (ld *device) SetLightPower(
 	transition time.Duration,
 	ack bool,
 ) error {
-	if ctx.Err() != nil {
-		return ctx.Err()
-	}
+	return lifxlan.Command(
+		ctx,
+		ld,
+		conn,
+		SetLightPower,
+		&RawSetLightPowerPayload{
+			Level:    power,
+			Duration: lifxlan.ConvertDuration(transition),
+		},
+		ack,
+	)
+}
 
-	if conn == nil {
-		newConn, err := ld.Dial()
-		if err != nil {
-			return err
-		}
-		defer newConn.Close()
-		conn = newConn
+// RawStateLightPowerPayload defines the struct to be used for encoding and
+// decoding.
+//
+// https://lan.developer.lifx.com/docs/information-messages#statelightpower---packet-118
+type RawStateLightPowerPayload struct {
+	Level lifxlan.Power
+}
 
-		if ctx.Err() != nil {
-			return ctx.Err()
-		}
-	}
+// MarshalBinary implements encoding.BinaryMarshaler.
+func (p *RawStateLightPowerPayload) MarshalBinary() ([]byte, error) {
+	return p.AppendBinary(make([]byte, 0, 2))
+}
 
-	var flags lifxlan.AckResFlag
-	if ack {
-		flags |= lifxlan.FlagAckRequired
+// AppendBinary implements lifxlan.BinaryAppender.
+func (p *RawStateLightPowerPayload) AppendBinary(b []byte) ([]byte, error) {
+	var buf [2]byte
+	binary.LittleEndian.PutUint16(buf[:], uint16(p.Level))
+	return append(b, buf[:]...), nil
+}
+
+// UnmarshalBinary implements encoding.BinaryUnmarshaler.
+func (p *RawStateLightPowerPayload) UnmarshalBinary(data []byte) error {
+	if len(data) < 2 {
+		return io.ErrUnexpectedEOF
 	}
+	p.Level = lifxlan.Power(binary.LittleEndian.Uint16(data))
+	return nil
+}
 
-	// Send
-	seq, err := ld.Send(
+func (ld *device) SetLightPowerAndGet(
+	ctx context.Context,
+	conn net.Conn,
+	power lifxlan.Power,
+	transition time.Duration,
+) (lifxlan.Power, error) {
+	var raw RawStateLightPowerPayload
+	if err := lifxlan.SetRequest(
 		ctx,
+		ld,
 		conn,
-		flags,
 		SetLightPower,
 		&RawSetLightPowerPayload{
 			Level:    power,
 			Duration: lifxlan.ConvertDuration(transition),
 		},
-	)
-	if err != nil {
-		return err
-	}
-
-	if ack {
-		return lifxlan.WaitForAcks(ctx, conn, ld.Source(), seq)
+		StateLightPower,
+		&raw,
+	); err != nil {
+		return 0, err
 	}
-	return nil
+	return raw.Level, nil
 }
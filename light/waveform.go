@@ -2,6 +2,8 @@ package light
 
 import (
 	"context"
+	"encoding/binary"
+	"io"
 	"math"
 	"net"
 	"time"
@@ -68,6 +70,54 @@ type RawSetWaveformOptionalPayload struct {
 	SetKelvin     BoolUint8
 }
 
+// RawSetWaveformOptionalPayloadLength is the length of the encoded
+// RawSetWaveformOptionalPayload.
+const RawSetWaveformOptionalPayloadLength = 25
+
+// MarshalBinary implements encoding.BinaryMarshaler.
+func (p *RawSetWaveformOptionalPayload) MarshalBinary() ([]byte, error) {
+	return p.AppendBinary(make([]byte, 0, RawSetWaveformOptionalPayloadLength))
+}
+
+// AppendBinary implements lifxlan.BinaryAppender.
+func (p *RawSetWaveformOptionalPayload) AppendBinary(b []byte) ([]byte, error) {
+	b = append(b, 0, uint8(p.Transient)) // reserved, transient
+	b, err := p.Color.AppendBinary(b)
+	if err != nil {
+		return b, err
+	}
+	var buf [RawSetWaveformOptionalPayloadLength - 2 - lifxlan.ColorLength]byte
+	binary.LittleEndian.PutUint32(buf[0:], uint32(p.Period))
+	binary.LittleEndian.PutUint32(buf[4:], math.Float32bits(p.Cycles))
+	binary.LittleEndian.PutUint16(buf[8:], uint16(p.SkewRatio))
+	buf[10] = uint8(p.Waveform)
+	buf[11] = uint8(p.SetHue)
+	buf[12] = uint8(p.SetSaturation)
+	buf[13] = uint8(p.SetBrightness)
+	buf[14] = uint8(p.SetKelvin)
+	return append(b, buf[:]...), nil
+}
+
+// UnmarshalBinary implements encoding.BinaryUnmarshaler.
+func (p *RawSetWaveformOptionalPayload) UnmarshalBinary(data []byte) error {
+	if len(data) < RawSetWaveformOptionalPayloadLength {
+		return io.ErrUnexpectedEOF
+	}
+	p.Transient = BoolUint8(data[1])
+	if err := p.Color.UnmarshalBinary(data[2:]); err != nil {
+		return err
+	}
+	p.Period = lifxlan.TransitionTime(binary.LittleEndian.Uint32(data[10:]))
+	p.Cycles = math.Float32frombits(binary.LittleEndian.Uint32(data[14:]))
+	p.SkewRatio = int16(binary.LittleEndian.Uint16(data[18:]))
+	p.Waveform = Waveform(data[20])
+	p.SetHue = BoolUint8(data[21])
+	p.SetSaturation = BoolUint8(data[22])
+	p.SetBrightness = BoolUint8(data[23])
+	p.SetKelvin = BoolUint8(data[24])
+	return nil
+}
+
 // SetWaveformArgs is the args to be translated into
 // RawSetWaveformOptionalPayload.
 type SetWaveformArgs struct {
@@ -116,33 +166,10 @@ func (ld *device) SetWaveform(
 	args *SetWaveformArgs,
 	ack bool,
 ) error {
-	if ctx.Err() != nil {
-		return ctx.Err()
-	}
-
-	if conn == nil {
-		newConn, err := ld.Dial()
-		if err != nil {
-			return err
-		}
-		defer newConn.Close()
-		conn = newConn
-
-		if ctx.Err() != nil {
-			return ctx.Err()
-		}
-	}
-
-	var flags lifxlan.AckResFlag
-	if ack {
-		flags |= lifxlan.FlagAckRequired
-	}
-
-	// Send
-	seq, err := ld.Send(
+	return lifxlan.Command(
 		ctx,
+		ld,
 		conn,
-		flags,
 		SetWaveformOptional,
 		&RawSetWaveformOptionalPayload{
 			Transient:     Bool2Uint8(args.Transient),
@@ -156,13 +183,6 @@ func (ld *device) SetWaveform(
 			SetBrightness: Bool2Uint8(!args.KeepBrightness),
 			SetKelvin:     Bool2Uint8(!args.KeepKelvin),
 		},
+		ack,
 	)
-	if err != nil {
-		return err
-	}
-
-	if ack {
-		return lifxlan.WaitForAcks(ctx, conn, ld.Source(), seq)
-	}
-	return nil
 }
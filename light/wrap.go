@@ -1,9 +1,7 @@
 package light
 
 import (
-	"bytes"
 	"context"
-	"encoding/binary"
 
 	"go.yhsif.com/lifxlan"
 )
@@ -31,59 +29,22 @@ func Wrap(ctx context.Context, d lifxlan.Device, force bool) (Device, error) {
 		}
 	}
 
-	conn, err := d.Dial()
-	if err != nil {
-		return nil, err
-	}
-	defer conn.Close()
-
-	if ctx.Err() != nil {
-		return nil, ctx.Err()
-	}
-
-	const msg = Get
-
-	seq, err := d.Send(
+	var raw RawStatePayload
+	if err := lifxlan.Request(
 		ctx,
-		conn,
-		0, // flags
-		msg,
+		d,
+		nil, // conn
+		Get,
 		nil, // payload
-	)
-	if err != nil {
+		State,
+		&raw,
+	); err != nil {
 		return nil, err
 	}
 
-	for {
-		resp, err := lifxlan.ReadNextResponse(ctx, conn)
-		if err != nil {
-			return nil, err
-		}
-		if resp.Sequence != seq || resp.Source != d.Source() {
-			continue
-		}
-
-		switch resp.Message {
-		case State:
-			var raw RawStatePayload
-			r := bytes.NewReader(resp.Payload)
-			if err := binary.Read(r, binary.LittleEndian, &raw); err != nil {
-				return nil, err
-			}
-
-			ld := &device{
-				Device: d,
-			}
-			*ld.Label() = raw.Label
-			return ld, nil
-
-		case lifxlan.StateUnhandled:
-			var raw lifxlan.RawStateUnhandledPayload
-			r := bytes.NewReader(resp.Payload)
-			if err := binary.Read(r, binary.LittleEndian, &raw); err != nil {
-				return nil, err
-			}
-			return nil, raw
-		}
+	ld := &device{
+		Device: d,
 	}
+	*ld.Label() = raw.Label
+	return ld, nil
 }
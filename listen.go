@@ -0,0 +1,126 @@
+package lifxlan
+
+import (
+	"context"
+	"net"
+)
+
+// Event is a message received by Listen or Client.Listen,
+// usually a state message sent by a device because another client
+// (e.g. the phone app or a switch) changed it.
+type Event struct {
+	// The target of the device sent the message.
+	Target Target
+
+	// The address the message is from.
+	Addr net.Addr
+
+	// The received message.
+	Response *Response
+
+	// The payload decoded via Decode,
+	// e.g. *RawStatePowerPayload for StatePower messages,
+	// *light.RawStatePayload for light.State messages,
+	// and *relay.RawStateRPowerPayload for relay.StateRPower messages
+	// (the subpackages need to be imported to register their messages).
+	//
+	// It's nil if the message type is not registered via RegisterMessage,
+	// or the message doesn't have a payload.
+	Payload interface{}
+}
+
+// ListenHandler handles the Events received by Listen and Client.Listen.
+//
+// It's called synchronously from the read loop,
+// so it should return quickly.
+// The Event must not be retained after the function returns.
+type ListenHandler func(event Event)
+
+// newEvent parses msg into an Event.
+func newEvent(addr net.Addr, msg []byte) (Event, error) {
+	resp, err := ParseResponse(msg)
+	if err != nil {
+		return Event{}, err
+	}
+	event := Event{
+		Target:   resp.Target,
+		Addr:     addr,
+		Response: resp,
+	}
+	if resp.Message.Name() != "" {
+		payload, err := Decode(resp)
+		if err != nil {
+			return Event{}, err
+		}
+		event.Payload = payload
+	}
+	return event, nil
+}
+
+// Listen binds DiscoverListenAddrFixed (the well-known port
+// DefaultBroadcastPort),
+// and calls handler with every message received from any source,
+// until ctx is done.
+//
+// Devices send their state messages to the well-known port when other clients
+// change them (e.g. the phone app or a switch),
+// so Listen can be used to keep an UI in sync with the changes made
+// elsewhere.
+// As all the messages are delivered,
+// handler usually type switches on Event.Payload to find the ones interested.
+//
+// It fails if the port is already taken by another app,
+// in which case use ListenPacket with a socket bound with SO_REUSEPORT,
+// or Client.Listen to share a Client's socket.
+//
+// It only returns upon error or when ctx is cancelled,
+// in which case ctx.Err() is returned.
+func Listen(ctx context.Context, handler ListenHandler) error {
+	conn, err := net.ListenPacket("udp", DiscoverListenAddrFixed)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	return ListenPacket(ctx, conn, handler)
+}
+
+// ListenPacket is similar to Listen,
+// but reads from conn instead of binding the well-known port.
+//
+// It doesn't close conn.
+// The caller shall not read from conn concurrently.
+//
+// The read timeout and buffer size are from the Config from the context.
+// Messages failed to be parsed or decoded are skipped,
+// and logged to the Logger of the Config from the context.
+// The received messages are reported to the Tracer from the context.
+func ListenPacket(ctx context.Context, conn net.PacketConn, handler ListenHandler) error {
+	config := ConfigFromContext(ctx)
+	tracer := TracerFromContext(ctx)
+	buf, done := config.readBuffer()
+	defer done()
+	for {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		if err := conn.SetReadDeadline(config.ReadDeadline()); err != nil {
+			return err
+		}
+		n, addr, err := conn.ReadFrom(buf)
+		if err != nil {
+			if CheckTimeoutError(err) {
+				continue
+			}
+			return err
+		}
+
+		event, err := newEvent(addr, buf[:n])
+		if err != nil {
+			config.Logf("lifxlan.Listen: skipping message from %v: %v", addr, err)
+			continue
+		}
+		tracer.OnReceive(event.Response)
+		handler(event)
+	}
+}
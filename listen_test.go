@@ -0,0 +1,181 @@
+package lifxlan_test
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"go.yhsif.com/lifxlan"
+	"go.yhsif.com/lifxlan/light"
+	"go.yhsif.com/lifxlan/mock"
+)
+
+// sendState sends a message with payload from a new socket to addr,
+// as if it's sent by a device with target.
+func sendState(
+	t *testing.T,
+	addr net.Addr,
+	target lifxlan.Target,
+	message lifxlan.MessageType,
+	payload lifxlan.BinaryAppender,
+) {
+	t.Helper()
+
+	var buf []byte
+	if payload != nil {
+		var err error
+		buf, err = payload.AppendBinary(nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	msg, err := lifxlan.GenerateMessage(
+		lifxlan.NotTagged,
+		1, // source
+		target,
+		0, // flags
+		0, // sequence
+		message,
+		buf,
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn, err := net.Dial("udp", addr.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if _, err := conn.Write(msg); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// waitEvent waits for the next event from events.
+func waitEvent(t *testing.T, events chan lifxlan.Event, timeout time.Duration) lifxlan.Event {
+	t.Helper()
+
+	select {
+	case event := <-events:
+		return event
+	case <-time.After(timeout):
+		t.Fatal("Timed out waiting for event")
+		return lifxlan.Event{}
+	}
+}
+
+func TestListenPacket(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping test in short mode.")
+	}
+
+	const timeout = time.Millisecond * 200
+
+	conn, err := net.ListenPacket("udp", mock.ListenAddr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	events := make(chan lifxlan.Event, 10)
+	errChan := make(chan error, 1)
+	go func() {
+		errChan <- lifxlan.ListenPacket(ctx, conn, func(event lifxlan.Event) {
+			events <- event
+		})
+	}()
+
+	const target lifxlan.Target = 2
+	sendState(t, conn.LocalAddr(), target, lifxlan.StatePower, &lifxlan.RawStatePowerPayload{
+		Level: lifxlan.PowerOn,
+	})
+	event := waitEvent(t, events, timeout)
+	if event.Target != target {
+		t.Errorf("Expected target %v, got %v", target, event.Target)
+	}
+	if event.Response.Message != lifxlan.StatePower {
+		t.Errorf("Expected %v, got %v", lifxlan.StatePower, event.Response.Message)
+	}
+	if payload, ok := event.Payload.(*lifxlan.RawStatePowerPayload); !ok {
+		t.Errorf("Expected *RawStatePowerPayload, got %#v", event.Payload)
+	} else if payload.Level != lifxlan.PowerOn {
+		t.Errorf("Expected %v, got %v", lifxlan.PowerOn, payload.Level)
+	}
+
+	var label lifxlan.Label
+	label.Set("foo")
+	sendState(t, conn.LocalAddr(), target, light.State, &light.RawStatePayload{
+		Label: label,
+	})
+	event = waitEvent(t, events, timeout)
+	if payload, ok := event.Payload.(*light.RawStatePayload); !ok {
+		t.Errorf("Expected *light.RawStatePayload, got %#v", event.Payload)
+	} else if payload.Label.String() != "foo" {
+		t.Errorf("Expected label %q, got %q", "foo", payload.Label)
+	}
+
+	// Unregistered messages are delivered without payload,
+	// invalid ones are skipped.
+	sendState(t, conn.LocalAddr(), target, lifxlan.StatePower, nil)
+	sendState(t, conn.LocalAddr(), target, 9999, nil)
+	event = waitEvent(t, events, timeout)
+	if event.Response.Message != 9999 || event.Payload != nil {
+		t.Errorf("Expected unregistered message without payload, got %+v", event)
+	}
+
+	if err := <-errChan; err != context.DeadlineExceeded {
+		t.Errorf("Expected %v, got %v", context.DeadlineExceeded, err)
+	}
+}
+
+func TestClientListen(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping test in short mode.")
+	}
+
+	const timeout = time.Millisecond * 200
+
+	client := newClient(t)
+	events := make(chan lifxlan.Event, 10)
+	stop := client.Listen(func(event lifxlan.Event) {
+		events <- event
+	})
+
+	sendState(t, client.LocalAddr(), mock.Target, lifxlan.StatePower, &lifxlan.RawStatePowerPayload{
+		Level: lifxlan.PowerOn,
+	})
+	event := waitEvent(t, events, timeout)
+	if event.Target != mock.Target {
+		t.Errorf("Expected target %v, got %v", mock.Target, event.Target)
+	}
+	if _, ok := event.Payload.(*lifxlan.RawStatePowerPayload); !ok {
+		t.Errorf("Expected *RawStatePowerPayload, got %#v", event.Payload)
+	}
+
+	// Responses to API calls are still routed to their connections.
+	service, device := mock.StartService(t)
+	service.RawStatePowerPayload = &lifxlan.RawStatePowerPayload{
+		Level: lifxlan.PowerOn,
+	}
+	conn, err := client.DialDevice(device)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	if _, err := device.GetPower(ctx, conn); err != nil {
+		t.Fatal(err)
+	}
+
+	stop()
+	sendState(t, client.LocalAddr(), mock.Target, lifxlan.StatePower, &lifxlan.RawStatePowerPayload{})
+	select {
+	case event := <-events:
+		t.Errorf("Expected no events after stop, got %+v", event)
+	case <-time.After(timeout / 4):
+	}
+}
@@ -0,0 +1,293 @@
+package lifxlan
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net"
+	"time"
+)
+
+// IDLength is the length of the IDs of locations and groups.
+const IDLength = 16
+
+// ID is the identifier of a location or a group.
+//
+// Devices with the same location (or group) ID belong to the same location
+// (or group).
+type ID [IDLength]byte
+
+// NewID generates a new random ID.
+func NewID() (ID, error) {
+	var id ID
+	if _, err := io.ReadFull(rand.Reader, id[:]); err != nil {
+		return id, err
+	}
+	return id, nil
+}
+
+func (id ID) String() string {
+	return hex.EncodeToString(id[:])
+}
+
+// MembershipLength is the length of the encoded Membership.
+const MembershipLength = IDLength + LabelLength + 8
+
+// Membership defines the location or the group of a device in message payloads
+// according to:
+//
+// https://lan.developer.lifx.com/docs/information-messages#statelocation---packet-50
+//
+// https://lan.developer.lifx.com/docs/information-messages#stategroup---packet-53
+//
+// As every device carries its own copy,
+// clients use the Label with the latest UpdatedAt among the devices with the
+// same ID.
+// So when changing the label of a location (or group),
+// or moving a device into an existing one,
+// UpdatedAt should be bumped via Touch.
+type Membership struct {
+	ID        ID
+	Label     Label
+	UpdatedAt Timestamp
+}
+
+// NewMembership creates a new location (or group) with a new random ID,
+// label, and UpdatedAt of now.
+//
+// If label is longer than LabelLength bytes,
+// the ErrLabelTruncated error from Label.Set is returned.
+func NewMembership(label string) (Membership, error) {
+	var m Membership
+	id, err := NewID()
+	if err != nil {
+		return m, err
+	}
+	m.ID = id
+	if err := m.Label.Set(label); err != nil {
+		return m, err
+	}
+	m.Touch()
+	return m, nil
+}
+
+// Touch bumps UpdatedAt to now,
+// or to 1 nanosecond after its current value if the current value is not
+// before now (e.g. set by a device with a clock ahead),
+// so that the change always wins over the other copies.
+func (m *Membership) Touch() {
+	now := ConvertTime(time.Now())
+	if now <= m.UpdatedAt {
+		now = m.UpdatedAt + 1
+	}
+	m.UpdatedAt = now
+}
+
+func (m Membership) String() string {
+	return fmt.Sprintf("%s(%v)", m.Label, m.ID)
+}
+
+// MarshalBinary implements encoding.BinaryMarshaler.
+func (m *Membership) MarshalBinary() ([]byte, error) {
+	return m.AppendBinary(make([]byte, 0, MembershipLength))
+}
+
+// AppendBinary implements BinaryAppender.
+func (m *Membership) AppendBinary(b []byte) ([]byte, error) {
+	b, buf := grow(b, MembershipLength)
+	copy(buf, m.ID[:])
+	copy(buf[IDLength:], m.Label[:])
+	binary.LittleEndian.PutUint64(buf[IDLength+LabelLength:], uint64(m.UpdatedAt))
+	return b, nil
+}
+
+// UnmarshalBinary implements encoding.BinaryUnmarshaler.
+func (m *Membership) UnmarshalBinary(data []byte) error {
+	if len(data) < MembershipLength {
+		return io.ErrUnexpectedEOF
+	}
+	copy(m.ID[:], data)
+	copy(m.Label[:], data[IDLength:])
+	m.UpdatedAt = Timestamp(binary.LittleEndian.Uint64(data[IDLength+LabelLength:]))
+	return nil
+}
+
+// RawStateLocationPayload defines the struct to be used for encoding and
+// decoding.
+//
+// https://lan.developer.lifx.com/docs/information-messages#statelocation---packet-50
+type RawStateLocationPayload struct {
+	Location Membership
+}
+
+// MarshalBinary implements encoding.BinaryMarshaler.
+func (p *RawStateLocationPayload) MarshalBinary() ([]byte, error) {
+	return p.Location.MarshalBinary()
+}
+
+// AppendBinary implements BinaryAppender.
+func (p *RawStateLocationPayload) AppendBinary(b []byte) ([]byte, error) {
+	return p.Location.AppendBinary(b)
+}
+
+// UnmarshalBinary implements encoding.BinaryUnmarshaler.
+func (p *RawStateLocationPayload) UnmarshalBinary(data []byte) error {
+	return p.Location.UnmarshalBinary(data)
+}
+
+// RawSetLocationPayload defines the struct to be used for encoding and
+// decoding.
+//
+// https://lan.developer.lifx.com/docs/changing-a-device#setlocation---packet-49
+type RawSetLocationPayload struct {
+	Location Membership
+}
+
+// MarshalBinary implements encoding.BinaryMarshaler.
+func (p *RawSetLocationPayload) MarshalBinary() ([]byte, error) {
+	return p.Location.MarshalBinary()
+}
+
+// AppendBinary implements BinaryAppender.
+func (p *RawSetLocationPayload) AppendBinary(b []byte) ([]byte, error) {
+	return p.Location.AppendBinary(b)
+}
+
+// UnmarshalBinary implements encoding.BinaryUnmarshaler.
+func (p *RawSetLocationPayload) UnmarshalBinary(data []byte) error {
+	return p.Location.UnmarshalBinary(data)
+}
+
+// RawStateGroupPayload defines the struct to be used for encoding and
+// decoding.
+//
+// https://lan.developer.lifx.com/docs/information-messages#stategroup---packet-53
+type RawStateGroupPayload struct {
+	Group Membership
+}
+
+// MarshalBinary implements encoding.BinaryMarshaler.
+func (p *RawStateGroupPayload) MarshalBinary() ([]byte, error) {
+	return p.Group.MarshalBinary()
+}
+
+// AppendBinary implements BinaryAppender.
+func (p *RawStateGroupPayload) AppendBinary(b []byte) ([]byte, error) {
+	return p.Group.AppendBinary(b)
+}
+
+// UnmarshalBinary implements encoding.BinaryUnmarshaler.
+func (p *RawStateGroupPayload) UnmarshalBinary(data []byte) error {
+	return p.Group.UnmarshalBinary(data)
+}
+
+// RawSetGroupPayload defines the struct to be used for encoding and decoding.
+//
+// https://lan.developer.lifx.com/docs/changing-a-device#setgroup---packet-52
+type RawSetGroupPayload struct {
+	Group Membership
+}
+
+// MarshalBinary implements encoding.BinaryMarshaler.
+func (p *RawSetGroupPayload) MarshalBinary() ([]byte, error) {
+	return p.Group.MarshalBinary()
+}
+
+// AppendBinary implements BinaryAppender.
+func (p *RawSetGroupPayload) AppendBinary(b []byte) ([]byte, error) {
+	return p.Group.AppendBinary(b)
+}
+
+// UnmarshalBinary implements encoding.BinaryUnmarshaler.
+func (p *RawSetGroupPayload) UnmarshalBinary(data []byte) error {
+	return p.Group.UnmarshalBinary(data)
+}
+
+func (d *device) Location() *Membership {
+	return &d.location
+}
+
+func (d *device) GetLocation(ctx context.Context, conn net.Conn) error {
+	var raw RawStateLocationPayload
+	if err := Request(
+		ctx,
+		d,
+		conn,
+		GetLocation,
+		nil, // payload
+		StateLocation,
+		&raw,
+	); err != nil {
+		return err
+	}
+	d.location = raw.Location
+	return nil
+}
+
+func (d *device) SetLocation(
+	ctx context.Context,
+	conn net.Conn,
+	location Membership,
+	ack bool,
+) error {
+	if err := Command(
+		ctx,
+		d,
+		conn,
+		SetLocation,
+		&RawSetLocationPayload{
+			Location: location,
+		},
+		ack,
+	); err != nil {
+		return err
+	}
+	d.location = location
+	return nil
+}
+
+func (d *device) Group() *Membership {
+	return &d.group
+}
+
+func (d *device) GetGroup(ctx context.Context, conn net.Conn) error {
+	var raw RawStateGroupPayload
+	if err := Request(
+		ctx,
+		d,
+		conn,
+		GetGroup,
+		nil, // payload
+		StateGroup,
+		&raw,
+	); err != nil {
+		return err
+	}
+	d.group = raw.Group
+	return nil
+}
+
+func (d *device) SetGroup(
+	ctx context.Context,
+	conn net.Conn,
+	group Membership,
+	ack bool,
+) error {
+	if err := Command(
+		ctx,
+		d,
+		conn,
+		SetGroup,
+		&RawSetGroupPayload{
+			Group: group,
+		},
+		ack,
+	); err != nil {
+		return err
+	}
+	d.group = group
+	return nil
+}
@@ -0,0 +1,139 @@
+package lifxlan_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"go.yhsif.com/lifxlan"
+	"go.yhsif.com/lifxlan/mock"
+)
+
+func TestNewMembership(t *testing.T) {
+	before := lifxlan.ConvertTime(time.Now())
+	m1, err := lifxlan.NewMembership("foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	m2, err := lifxlan.NewMembership("foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if m1.ID == (lifxlan.ID{}) {
+		t.Error("Expected non-zero ID")
+	}
+	if m1.ID == m2.ID {
+		t.Errorf("Expected different IDs, got %v twice", m1.ID)
+	}
+	if m1.Label.String() != "foo" {
+		t.Errorf("Expected label %q, got %q", "foo", m1.Label)
+	}
+	if m1.UpdatedAt < before {
+		t.Errorf("Expected UpdatedAt >= %v, got %v", before, m1.UpdatedAt)
+	}
+}
+
+func TestMembershipTouch(t *testing.T) {
+	t.Run(
+		"Past",
+		func(t *testing.T) {
+			m := lifxlan.Membership{
+				UpdatedAt: lifxlan.ConvertTime(time.Now().Add(-time.Hour)),
+			}
+			before := lifxlan.ConvertTime(time.Now())
+			m.Touch()
+			if m.UpdatedAt < before {
+				t.Errorf("Expected UpdatedAt >= %v, got %v", before, m.UpdatedAt)
+			}
+		},
+	)
+
+	t.Run(
+		"Future",
+		func(t *testing.T) {
+			future := lifxlan.ConvertTime(time.Now().Add(time.Hour))
+			m := lifxlan.Membership{
+				UpdatedAt: future,
+			}
+			m.Touch()
+			if m.UpdatedAt != future+1 {
+				t.Errorf("Expected UpdatedAt %v, got %v", future+1, m.UpdatedAt)
+			}
+		},
+	)
+}
+
+func TestLocationAndGroup(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping test in short mode.")
+	}
+
+	const timeout = time.Millisecond * 200
+
+	location, err := lifxlan.NewMembership("Home")
+	if err != nil {
+		t.Fatal(err)
+	}
+	group, err := lifxlan.NewMembership("Bedroom")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	service, device := mock.StartService(t)
+	service.RawStateLocationPayload = &lifxlan.RawStateLocationPayload{
+		Location: location,
+	}
+	service.RawStateGroupPayload = &lifxlan.RawStateGroupPayload{
+		Group: group,
+	}
+
+	t.Run(
+		"Get",
+		func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), timeout)
+			defer cancel()
+
+			if err := device.GetLocation(ctx, nil); err != nil {
+				t.Fatal(err)
+			}
+			if *device.Location() != location {
+				t.Errorf("Location expected %v, got %v", location, device.Location())
+			}
+			if err := device.GetGroup(ctx, nil); err != nil {
+				t.Fatal(err)
+			}
+			if *device.Group() != group {
+				t.Errorf("Group expected %v, got %v", group, device.Group())
+			}
+		},
+	)
+
+	t.Run(
+		"Set",
+		func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), timeout)
+			defer cancel()
+
+			newLocation := location
+			newLocation.Touch()
+			if err := device.SetLocation(ctx, nil, newLocation, true); err != nil {
+				t.Fatal(err)
+			}
+			if *device.Location() != newLocation {
+				t.Errorf("Location expected %v, got %v", newLocation, device.Location())
+			}
+
+			newGroup, err := lifxlan.NewMembership("Kitchen")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := device.SetGroup(ctx, nil, newGroup, true); err != nil {
+				t.Fatal(err)
+			}
+			if *device.Group() != newGroup {
+				t.Errorf("Group expected %v, got %v", newGroup, device.Group())
+			}
+		},
+	)
+}
@@ -16,9 +16,42 @@ const (
 	StatePower        MessageType = 22
 	SetPower          MessageType = 21
 	GetLabel          MessageType = 23
+	SetLabel          MessageType = 24
 	StateLabel        MessageType = 25
 	GetVersion        MessageType = 32
 	StateVersion      MessageType = 33
+	GetLocation       MessageType = 48
+	SetLocation       MessageType = 49
+	StateLocation     MessageType = 50
+	GetGroup          MessageType = 51
+	SetGroup          MessageType = 52
+	StateGroup        MessageType = 53
 	EchoRequest       MessageType = 58
 	EchoResponse      MessageType = 59
 )
+
+func init() {
+	RegisterMessage(Acknowledgement, "Acknowledgement", nil)
+	RegisterMessage(StateUnhandled, "StateUnhandled", RawStateUnhandledPayload{})
+
+	RegisterMessage(GetService, "GetService", nil)
+	RegisterMessage(StateService, "StateService", RawStateServicePayload{})
+	RegisterMessage(GetHostFirmware, "GetHostFirmware", nil)
+	RegisterMessage(StateHostFirmware, "StateHostFirmware", RawStateHostFirmwarePayload{})
+	RegisterMessage(GetPower, "GetPower", nil)
+	RegisterMessage(SetPower, "SetPower", RawSetPowerPayload{})
+	RegisterMessage(StatePower, "StatePower", RawStatePowerPayload{})
+	RegisterMessage(GetLabel, "GetLabel", nil)
+	RegisterMessage(SetLabel, "SetLabel", RawSetLabelPayload{})
+	RegisterMessage(StateLabel, "StateLabel", RawStateLabelPayload{})
+	RegisterMessage(GetVersion, "GetVersion", nil)
+	RegisterMessage(StateVersion, "StateVersion", RawStateVersionPayload{})
+	RegisterMessage(GetLocation, "GetLocation", nil)
+	RegisterMessage(SetLocation, "SetLocation", RawSetLocationPayload{})
+	RegisterMessage(StateLocation, "StateLocation", RawStateLocationPayload{})
+	RegisterMessage(GetGroup, "GetGroup", nil)
+	RegisterMessage(SetGroup, "SetGroup", RawSetGroupPayload{})
+	RegisterMessage(StateGroup, "StateGroup", RawStateGroupPayload{})
+	RegisterMessage(EchoRequest, "EchoRequest", RawEchoRequestPayload{})
+	RegisterMessage(EchoResponse, "EchoResponse", RawEchoResponsePayload{})
+}
@@ -25,6 +25,10 @@ type HandlerFunc func(s *Service, conn net.PacketConn, addr net.Addr, orig *lifx
 
 // DefaultHandlerFunc is the default HandlerFunc to be used when it's not in the
 // Handlers map.
+//
+// For Get messages, it replies with the payloads set in Service.
+// For Set messages with FlagResRequired,
+// it replies with the state applied from the Set payload.
 func DefaultHandlerFunc(
 	s *Service,
 	conn net.PacketConn,
@@ -71,6 +75,42 @@ func DefaultHandlerFunc(
 		}
 		s.Reply(conn, addr, orig, lifxlan.StateVersion, buf.Bytes())
 
+	case lifxlan.GetHostFirmware:
+		buf := new(bytes.Buffer)
+		if err := binary.Write(
+			buf,
+			binary.LittleEndian,
+			s.RawStateHostFirmwarePayload,
+		); err != nil {
+			s.TB.Log(err)
+			return
+		}
+		s.Reply(conn, addr, orig, lifxlan.StateHostFirmware, buf.Bytes())
+
+	case lifxlan.GetLocation:
+		buf := new(bytes.Buffer)
+		if err := binary.Write(
+			buf,
+			binary.LittleEndian,
+			s.RawStateLocationPayload,
+		); err != nil {
+			s.TB.Log(err)
+			return
+		}
+		s.Reply(conn, addr, orig, lifxlan.StateLocation, buf.Bytes())
+
+	case lifxlan.GetGroup:
+		buf := new(bytes.Buffer)
+		if err := binary.Write(
+			buf,
+			binary.LittleEndian,
+			s.RawStateGroupPayload,
+		); err != nil {
+			s.TB.Log(err)
+			return
+		}
+		s.Reply(conn, addr, orig, lifxlan.StateGroup, buf.Bytes())
+
 	case lifxlan.EchoRequest:
 		buf := new(bytes.Buffer)
 		var echoing [lifxlan.EchoPayloadLength]byte
@@ -136,6 +176,117 @@ func DefaultHandlerFunc(
 			}
 			s.Reply(conn, addr, orig, tile.StateTileState64, buf.Bytes())
 		}
+
+	case lifxlan.SetPower:
+		if !orig.ResRequired() {
+			return
+		}
+		var raw lifxlan.RawSetPowerPayload
+		if err := orig.DecodePayload(&raw); err != nil {
+			s.TB.Log(err)
+			return
+		}
+		buf := new(bytes.Buffer)
+		if err := binary.Write(
+			buf,
+			binary.LittleEndian,
+			&lifxlan.RawStatePowerPayload{
+				Level: raw.Level,
+			},
+		); err != nil {
+			s.TB.Log(err)
+			return
+		}
+		s.Reply(conn, addr, orig, lifxlan.StatePower, buf.Bytes())
+
+	case lifxlan.SetLabel:
+		if !orig.ResRequired() {
+			return
+		}
+		var raw lifxlan.RawSetLabelPayload
+		if err := orig.DecodePayload(&raw); err != nil {
+			s.TB.Log(err)
+			return
+		}
+		buf := new(bytes.Buffer)
+		if err := binary.Write(
+			buf,
+			binary.LittleEndian,
+			&lifxlan.RawStateLabelPayload{
+				Label: raw.Label,
+			},
+		); err != nil {
+			s.TB.Log(err)
+			return
+		}
+		s.Reply(conn, addr, orig, lifxlan.StateLabel, buf.Bytes())
+
+	case light.SetColor:
+		if !orig.ResRequired() {
+			return
+		}
+		var raw light.RawSetColorPayload
+		if err := orig.DecodePayload(&raw); err != nil {
+			s.TB.Log(err)
+			return
+		}
+		var state light.RawStatePayload
+		if s.RawStatePayload != nil {
+			state = *s.RawStatePayload
+		}
+		state.Color = raw.Color
+		buf := new(bytes.Buffer)
+		if err := binary.Write(buf, binary.LittleEndian, &state); err != nil {
+			s.TB.Log(err)
+			return
+		}
+		s.Reply(conn, addr, orig, light.State, buf.Bytes())
+
+	case light.SetLightPower:
+		if !orig.ResRequired() {
+			return
+		}
+		var raw light.RawSetLightPowerPayload
+		if err := orig.DecodePayload(&raw); err != nil {
+			s.TB.Log(err)
+			return
+		}
+		buf := new(bytes.Buffer)
+		if err := binary.Write(
+			buf,
+			binary.LittleEndian,
+			&light.RawStateLightPowerPayload{
+				Level: raw.Level,
+			},
+		); err != nil {
+			s.TB.Log(err)
+			return
+		}
+		s.Reply(conn, addr, orig, light.StateLightPower, buf.Bytes())
+
+	case relay.SetRPower:
+		if !orig.ResRequired() {
+			return
+		}
+		var raw relay.RawSetRPowerPayload
+		if err := orig.DecodePayload(&raw); err != nil {
+			s.TB.Log(err)
+			return
+		}
+		buf := new(bytes.Buffer)
+		if err := binary.Write(
+			buf,
+			binary.LittleEndian,
+			&relay.RawStateRPowerPayload{
+				Index: raw.Index,
+				Level: raw.Level,
+			},
+		); err != nil {
+			s.TB.Log(err)
+			return
+		}
+		s.Reply(conn, addr, orig, relay.StateRPower, buf.Bytes())
+
 	}
 }
 
@@ -197,6 +348,9 @@ type Service struct {
 	RawStatePowerPayload        *lifxlan.RawStatePowerPayload
 	RawStateLabelPayload        *lifxlan.RawStateLabelPayload
 	RawStateVersionPayload      *lifxlan.RawStateVersionPayload
+	RawStateHostFirmwarePayload *lifxlan.RawStateHostFirmwarePayload
+	RawStateLocationPayload     *lifxlan.RawStateLocationPayload
+	RawStateGroupPayload        *lifxlan.RawStateGroupPayload
 	RawStatePayload             *light.RawStatePayload
 	RawStateRPowerPayload       *relay.RawStateRPowerPayload
 	RawStateDeviceChainPayload  *tile.RawStateDeviceChainPayload
@@ -0,0 +1,342 @@
+package lifxlan
+
+import (
+	"net"
+	"sync"
+	"time"
+)
+
+// Default values for ConnPool.
+const (
+	DefaultIdleTimeout         = time.Minute
+	DefaultMaxIdleConnsPerAddr = 2
+)
+
+// ConnPool is a Transport that reuses connections.
+//
+// Connections returned by ConnPool.Dial are checked out exclusively:
+// concurrent API calls to the same device get different connections,
+// so they won't eat each other's responses.
+// Closing a connection returned by ConnPool.Dial returns the underlying
+// connection to the pool instead of closing it,
+// unless it has encountered a network error,
+// in which case it's closed and the next Dial makes a new one.
+// Idle connections are closed after IdleTimeout.
+//
+// As a Transport, it's mainly used with WithTransport option,
+// so that the API calls with nil conn reuse connections instead of dialing
+// and closing a new one on every call:
+//
+//     pool := lifxlan.NewConnPool(nil)
+//     defer pool.Close()
+//     device := lifxlan.NewDevice(addr, lifxlan.ServiceUDP, target, lifxlan.WithTransport(pool))
+//
+// The same ConnPool can be shared by multiple devices.
+// It's safe for concurrent use.
+type ConnPool struct {
+	// The Transport used to dial new connections,
+	// nil means DefaultTransport.
+	Transport Transport
+
+	// Idle connections will be closed after IdleTimeout.
+	//
+	// Values <= 0 mean DefaultIdleTimeout.
+	IdleTimeout time.Duration
+
+	// The max number of idle connections kept for every address.
+	//
+	// Values <= 0 mean DefaultMaxIdleConnsPerAddr.
+	MaxIdleConnsPerAddr int
+
+	lock   sync.Mutex
+	idle   map[poolKey][]*idleConn
+	closed bool
+}
+
+type poolKey struct {
+	network string
+	address string
+}
+
+type idleConn struct {
+	conn  net.Conn
+	timer *time.Timer
+}
+
+// NewConnPool creates a new ConnPool using transport to dial new connections.
+//
+// nil transport means DefaultTransport.
+func NewConnPool(transport Transport) *ConnPool {
+	return &ConnPool{
+		Transport: transport,
+	}
+}
+
+func (p *ConnPool) idleTimeout() time.Duration {
+	if p.IdleTimeout > 0 {
+		return p.IdleTimeout
+	}
+	return DefaultIdleTimeout
+}
+
+func (p *ConnPool) maxIdle() int {
+	if p.MaxIdleConnsPerAddr > 0 {
+		return p.MaxIdleConnsPerAddr
+	}
+	return DefaultMaxIdleConnsPerAddr
+}
+
+func (p *ConnPool) dial(key poolKey) (net.Conn, error) {
+	transport := p.Transport
+	if transport == nil {
+		transport = DefaultTransport
+	}
+	return transport.Dial(key.network, key.address)
+}
+
+// Dial returns an idle connection to address if there's one,
+// or dials a new one.
+//
+// The returned connection must be closed after use to return it to the pool.
+func (p *ConnPool) Dial(network, address string) (net.Conn, error) {
+	key := poolKey{
+		network: network,
+		address: address,
+	}
+
+	p.lock.Lock()
+	if p.closed {
+		p.lock.Unlock()
+		return nil, ErrConnPoolClosed
+	}
+	var conn net.Conn
+	if idle := p.idle[key]; len(idle) > 0 {
+		// Take the most recently used one.
+		ic := idle[len(idle)-1]
+		p.idle[key] = idle[:len(idle)-1]
+		ic.timer.Stop()
+		conn = ic.conn
+	}
+	p.lock.Unlock()
+
+	if conn == nil {
+		var err error
+		conn, err = p.dial(key)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return &pooledConn{
+		pool: p,
+		key:  key,
+		conn: conn,
+	}, nil
+}
+
+// put returns conn to the pool,
+// or closes it if the pool is closed or full.
+func (p *ConnPool) put(key poolKey, conn net.Conn) error {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if p.closed || len(p.idle[key]) >= p.maxIdle() {
+		return conn.Close()
+	}
+	if p.idle == nil {
+		p.idle = make(map[poolKey][]*idleConn)
+	}
+	ic := &idleConn{
+		conn: conn,
+	}
+	ic.timer = time.AfterFunc(p.idleTimeout(), func() {
+		p.expire(key, ic)
+	})
+	p.idle[key] = append(p.idle[key], ic)
+	return nil
+}
+
+// expire removes ic from the pool and closes it,
+// if it's still idle.
+func (p *ConnPool) expire(key poolKey, ic *idleConn) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	idle := p.idle[key]
+	for i, c := range idle {
+		if c == ic {
+			p.idle[key] = append(idle[:i], idle[i+1:]...)
+			if len(p.idle[key]) == 0 {
+				delete(p.idle, key)
+			}
+			ic.conn.Close()
+			return
+		}
+	}
+}
+
+// Close closes all the idle connections.
+//
+// Connections currently checked out will be closed when they are closed by
+// the caller, and Dial will fail with ErrConnPoolClosed after Close.
+func (p *ConnPool) Close() error {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	p.closed = true
+	var err error
+	for _, idle := range p.idle {
+		for _, ic := range idle {
+			ic.timer.Stop()
+			if e := ic.conn.Close(); e != nil && err == nil {
+				err = e
+			}
+		}
+	}
+	p.idle = nil
+	return err
+}
+
+// pooledConn is the net.Conn implementation returned by ConnPool.Dial.
+type pooledConn struct {
+	pool *ConnPool
+	key  poolKey
+
+	lock     sync.Mutex
+	conn     net.Conn
+	broken   bool
+	released bool
+}
+
+var _ net.Conn = (*pooledConn)(nil)
+
+// current returns the current underlying connection,
+// or ErrConnPoolClosed if it's already released.
+func (pc *pooledConn) current() (net.Conn, error) {
+	pc.lock.Lock()
+	defer pc.lock.Unlock()
+	if pc.released {
+		return nil, ErrConnPoolClosed
+	}
+	return pc.conn, nil
+}
+
+// fail marks the connection as broken if err is a network error other than
+// timeout.
+func (pc *pooledConn) fail(err error) {
+	if err == nil || CheckTimeoutError(err) {
+		return
+	}
+	pc.lock.Lock()
+	defer pc.lock.Unlock()
+	pc.broken = true
+}
+
+// redial replaces the underlying connection failed with a newly dialed one,
+// unless it's already replaced by another goroutine.
+func (pc *pooledConn) redial(failed net.Conn) (net.Conn, error) {
+	pc.lock.Lock()
+	defer pc.lock.Unlock()
+	if pc.released {
+		return nil, ErrConnPoolClosed
+	}
+	if pc.conn != failed {
+		return pc.conn, nil
+	}
+	conn, err := pc.pool.dial(pc.key)
+	if err != nil {
+		pc.broken = true
+		return nil, err
+	}
+	failed.Close()
+	pc.conn = conn
+	return conn, nil
+}
+
+func (pc *pooledConn) Read(b []byte) (int, error) {
+	conn, err := pc.current()
+	if err != nil {
+		return 0, err
+	}
+	n, err := conn.Read(b)
+	pc.fail(err)
+	return n, err
+}
+
+// Write writes b to the underlying connection.
+//
+// If the write fails with a network error,
+// the underlying connection is replaced by a newly dialed one,
+// and the write is retried once.
+func (pc *pooledConn) Write(b []byte) (int, error) {
+	conn, err := pc.current()
+	if err != nil {
+		return 0, err
+	}
+	n, err := conn.Write(b)
+	if err == nil || CheckTimeoutError(err) {
+		return n, err
+	}
+
+	conn, dialErr := pc.redial(conn)
+	if dialErr != nil {
+		return n, err
+	}
+	n, err = conn.Write(b)
+	pc.fail(err)
+	return n, err
+}
+
+// Close returns the underlying connection to the pool,
+// or closes it if it has encountered a network error.
+func (pc *pooledConn) Close() error {
+	pc.lock.Lock()
+	if pc.released {
+		pc.lock.Unlock()
+		return nil
+	}
+	pc.released = true
+	broken := pc.broken
+	conn := pc.conn
+	pc.lock.Unlock()
+
+	if broken {
+		return conn.Close()
+	}
+	return pc.pool.put(pc.key, conn)
+}
+
+func (pc *pooledConn) LocalAddr() net.Addr {
+	pc.lock.Lock()
+	defer pc.lock.Unlock()
+	return pc.conn.LocalAddr()
+}
+
+func (pc *pooledConn) RemoteAddr() net.Addr {
+	pc.lock.Lock()
+	defer pc.lock.Unlock()
+	return pc.conn.RemoteAddr()
+}
+
+func (pc *pooledConn) SetDeadline(t time.Time) error {
+	conn, err := pc.current()
+	if err != nil {
+		return err
+	}
+	return conn.SetDeadline(t)
+}
+
+func (pc *pooledConn) SetReadDeadline(t time.Time) error {
+	conn, err := pc.current()
+	if err != nil {
+		return err
+	}
+	return conn.SetReadDeadline(t)
+}
+
+func (pc *pooledConn) SetWriteDeadline(t time.Time) error {
+	conn, err := pc.current()
+	if err != nil {
+		return err
+	}
+	return conn.SetWriteDeadline(t)
+}
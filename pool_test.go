@@ -0,0 +1,258 @@
+package lifxlan_test
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"go.yhsif.com/lifxlan"
+	"go.yhsif.com/lifxlan/mock"
+)
+
+// countTransport is a Transport counting the dials.
+type countTransport struct {
+	lock  sync.Mutex
+	dials int
+
+	dial func(network, address string) (net.Conn, error)
+}
+
+func (t *countTransport) Dial(network, address string) (net.Conn, error) {
+	t.lock.Lock()
+	t.dials++
+	t.lock.Unlock()
+	return t.dial(network, address)
+}
+
+func (t *countTransport) count() int {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	return t.dials
+}
+
+// failConn is a net.Conn failing reads and writes with the errors.
+type failConn struct {
+	net.Conn
+
+	readErr  error
+	writeErr error
+	closed   bool
+}
+
+func (c *failConn) Read(b []byte) (int, error) {
+	return 0, c.readErr
+}
+
+func (c *failConn) Write(b []byte) (int, error) {
+	if c.writeErr != nil {
+		return 0, c.writeErr
+	}
+	return len(b), nil
+}
+
+func (c *failConn) Close() error {
+	c.closed = true
+	return nil
+}
+
+func TestConnPool(t *testing.T) {
+	t.Run(
+		"Reuse",
+		func(t *testing.T) {
+			if testing.Short() {
+				t.Skip("skipping test in short mode.")
+			}
+
+			const timeout = time.Millisecond * 200
+
+			service, mockDevice := mock.StartService(t)
+			service.RawStatePowerPayload = &lifxlan.RawStatePowerPayload{
+				Level: lifxlan.PowerOn,
+			}
+			transport := &countTransport{
+				dial: net.Dial,
+			}
+			pool := lifxlan.NewConnPool(transport)
+			defer pool.Close()
+			device := lifxlan.NewDevice(
+				mockDevice.Addr().String(),
+				lifxlan.ServiceUDP,
+				mockDevice.Target(),
+				lifxlan.WithTransport(pool),
+			)
+
+			for i := 0; i < 3; i++ {
+				ctx, cancel := context.WithTimeout(context.Background(), timeout)
+				defer cancel()
+				if _, err := device.GetPower(ctx, nil); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if n := transport.count(); n != 1 {
+				t.Errorf("Expected 1 dial, got %d", n)
+			}
+		},
+	)
+
+	t.Run(
+		"Exclusive",
+		func(t *testing.T) {
+			transport := &countTransport{
+				dial: func(string, string) (net.Conn, error) {
+					return new(failConn), nil
+				},
+			}
+			pool := lifxlan.NewConnPool(transport)
+			defer pool.Close()
+
+			conn1, err := pool.Dial("udp", "foo")
+			if err != nil {
+				t.Fatal(err)
+			}
+			conn2, err := pool.Dial("udp", "foo")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if n := transport.count(); n != 2 {
+				t.Errorf("Expected 2 dials for concurrent checkouts, got %d", n)
+			}
+			conn1.Close()
+			conn2.Close()
+
+			conn3, err := pool.Dial("udp", "foo")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer conn3.Close()
+			if n := transport.count(); n != 2 {
+				t.Errorf("Expected idle connection to be reused, got %d dials", n)
+			}
+			if _, err := conn1.Write(nil); !errors.Is(err, lifxlan.ErrConnPoolClosed) {
+				t.Errorf("Expected %v after Close, got %v", lifxlan.ErrConnPoolClosed, err)
+			}
+		},
+	)
+
+	t.Run(
+		"IdleTimeout",
+		func(t *testing.T) {
+			const idleTimeout = time.Millisecond * 10
+
+			var dialed []*failConn
+			transport := &countTransport{
+				dial: func(string, string) (net.Conn, error) {
+					conn := new(failConn)
+					dialed = append(dialed, conn)
+					return conn, nil
+				},
+			}
+			pool := lifxlan.NewConnPool(transport)
+			pool.IdleTimeout = idleTimeout
+			defer pool.Close()
+
+			conn, err := pool.Dial("udp", "foo")
+			if err != nil {
+				t.Fatal(err)
+			}
+			conn.Close()
+			time.Sleep(idleTimeout * 5)
+
+			conn, err = pool.Dial("udp", "foo")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer conn.Close()
+			if n := transport.count(); n != 2 {
+				t.Errorf("Expected 2 dials after idle timeout, got %d", n)
+			}
+			if !dialed[0].closed {
+				t.Error("Expected idle connection to be closed")
+			}
+		},
+	)
+
+	t.Run(
+		"Broken",
+		func(t *testing.T) {
+			readErr := errors.New("read error")
+			var dialed []*failConn
+			transport := &countTransport{
+				dial: func(string, string) (net.Conn, error) {
+					conn := &failConn{
+						readErr: readErr,
+					}
+					if len(dialed) == 0 {
+						conn.writeErr = errors.New("write error")
+					}
+					dialed = append(dialed, conn)
+					return conn, nil
+				},
+			}
+			pool := lifxlan.NewConnPool(transport)
+			defer pool.Close()
+
+			conn, err := pool.Dial("udp", "foo")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := conn.Write([]byte("foo")); err != nil {
+				t.Errorf("Expected write to succeed after redial, got %v", err)
+			}
+			if n := transport.count(); n != 2 {
+				t.Errorf("Expected 2 dials after write error, got %d", n)
+			}
+			if !dialed[0].closed {
+				t.Error("Expected failed connection to be closed")
+			}
+
+			if _, err := conn.Read(nil); err != readErr {
+				t.Errorf("Expected %v, got %v", readErr, err)
+			}
+			conn.Close()
+			if !dialed[1].closed {
+				t.Error("Expected broken connection to be closed instead of reused")
+			}
+
+			conn, err = pool.Dial("udp", "foo")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer conn.Close()
+			if n := transport.count(); n != 3 {
+				t.Errorf("Expected 3 dials after read error, got %d", n)
+			}
+		},
+	)
+
+	t.Run(
+		"Closed",
+		func(t *testing.T) {
+			var dialed []*failConn
+			pool := lifxlan.NewConnPool(lifxlan.TransportFunc(
+				func(string, string) (net.Conn, error) {
+					conn := new(failConn)
+					dialed = append(dialed, conn)
+					return conn, nil
+				},
+			))
+
+			conn, err := pool.Dial("udp", "foo")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := pool.Close(); err != nil {
+				t.Fatal(err)
+			}
+			conn.Close()
+			if !dialed[0].closed {
+				t.Error("Expected connection to be closed after pool closed")
+			}
+			if _, err := pool.Dial("udp", "foo"); !errors.Is(err, lifxlan.ErrConnPoolClosed) {
+				t.Errorf("Expected %v, got %v", lifxlan.ErrConnPoolClosed, err)
+			}
+		},
+	)
+}
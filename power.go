@@ -1,9 +1,9 @@
 package lifxlan
 
 import (
-	"bytes"
 	"context"
 	"encoding/binary"
+	"io"
 	"net"
 )
 
@@ -38,55 +38,41 @@ type RawStatePowerPayload struct {
 	Level Power
 }
 
-func (d *device) GetPower(ctx context.Context, conn net.Conn) (Power, error) {
-	if ctx.Err() != nil {
-		return 0, ctx.Err()
-	}
+// MarshalBinary implements encoding.BinaryMarshaler.
+func (p *RawStatePowerPayload) MarshalBinary() ([]byte, error) {
+	return p.AppendBinary(make([]byte, 0, 2))
+}
+
+// AppendBinary implements BinaryAppender.
+func (p *RawStatePowerPayload) AppendBinary(b []byte) ([]byte, error) {
+	b, buf := grow(b, 2)
+	binary.LittleEndian.PutUint16(buf, uint16(p.Level))
+	return b, nil
+}
 
-	if conn == nil {
-		newConn, err := d.Dial()
-		if err != nil {
-			return 0, err
-		}
-		defer newConn.Close()
-		conn = newConn
-
-		if ctx.Err() != nil {
-			return 0, ctx.Err()
-		}
+// UnmarshalBinary implements encoding.BinaryUnmarshaler.
+func (p *RawStatePowerPayload) UnmarshalBinary(data []byte) error {
+	if len(data) < 2 {
+		return io.ErrUnexpectedEOF
 	}
+	p.Level = Power(binary.LittleEndian.Uint16(data))
+	return nil
+}
 
-	seq, err := d.Send(
+func (d *device) GetPower(ctx context.Context, conn net.Conn) (Power, error) {
+	var raw RawStatePowerPayload
+	if err := Request(
 		ctx,
+		d,
 		conn,
-		0, // flags
 		GetPower,
 		nil, // payload
-	)
-	if err != nil {
+		StatePower,
+		&raw,
+	); err != nil {
 		return 0, err
 	}
-
-	for {
-		resp, err := ReadNextResponse(ctx, conn)
-		if err != nil {
-			return 0, err
-		}
-		if resp.Sequence != seq || resp.Source != d.Source() {
-			continue
-		}
-		if resp.Message != StatePower {
-			continue
-		}
-
-		var raw RawStatePowerPayload
-		r := bytes.NewReader(resp.Payload)
-		if err := binary.Read(r, binary.LittleEndian, &raw); err != nil {
-			return 0, err
-		}
-
-		return raw.Level, nil
-	}
+	return raw.Level, nil
 }
 
 // RawSetPowerPayload defines the struct to be used for encoding and decoding.
@@ -96,52 +82,63 @@ type RawSetPowerPayload struct {
 	Level Power
 }
 
+// MarshalBinary implements encoding.BinaryMarshaler.
+func (p *RawSetPowerPayload) MarshalBinary() ([]byte, error) {
+	return p.AppendBinary(make([]byte, 0, 2))
+}
+
+// AppendBinary implements BinaryAppender.
+func (p *RawSetPowerPayload) AppendBinary(b []byte) ([]byte, error) {
+	b, buf := grow(b, 2)
+	binary.LittleEndian.PutUint16(buf, uint16(p.Level))
+	return b, nil
+}
+
+// UnmarshalBinary implements encoding.BinaryUnmarshaler.
+func (p *RawSetPowerPayload) UnmarshalBinary(data []byte) error {
+	if len(data) < 2 {
+		return io.ErrUnexpectedEOF
+	}
+	p.Level = Power(binary.LittleEndian.Uint16(data))
+	return nil
+}
+
 func (d *device) SetPower(
 	ctx context.Context,
 	conn net.Conn,
 	power Power,
 	ack bool,
 ) error {
-	if ctx.Err() != nil {
-		return ctx.Err()
-	}
-
-	if conn == nil {
-		newConn, err := d.Dial()
-		if err != nil {
-			return err
-		}
-		defer newConn.Close()
-		conn = newConn
-
-		if ctx.Err() != nil {
-			return ctx.Err()
-		}
-	}
-
-	var flags AckResFlag
-	if ack {
-		flags |= FlagAckRequired
-	}
-
-	seq, err := d.Send(
+	return Command(
 		ctx,
+		d,
 		conn,
-		flags,
 		SetPower,
 		&RawSetPowerPayload{
 			Level: power,
 		},
+		ack,
 	)
-	if err != nil {
-		return err
-	}
+}
 
-	if ack {
-		if err := WaitForAcks(ctx, conn, d.Source(), seq); err != nil {
-			return err
-		}
+func (d *device) SetPowerAndGet(
+	ctx context.Context,
+	conn net.Conn,
+	power Power,
+) (Power, error) {
+	var raw RawStatePowerPayload
+	if err := SetRequest(
+		ctx,
+		d,
+		conn,
+		SetPower,
+		&RawSetPowerPayload{
+			Level: power,
+		},
+		StatePower,
+		&raw,
+	); err != nil {
+		return 0, err
 	}
-
-	return nil
+	return raw.Level, nil
 }
@@ -156,3 +156,26 @@ func TestSetPower(t *testing.T) {
 		t.Error("SetPower message not received.")
 	}
 }
+
+func TestSetPowerAndGet(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping test in short mode.")
+	}
+
+	const timeout = time.Millisecond * 200
+
+	_, device := mock.StartService(t)
+
+	for _, expected := range []lifxlan.Power{lifxlan.PowerOn, lifxlan.PowerOff} {
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		defer cancel()
+
+		power, err := device.SetPowerAndGet(ctx, nil, expected)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if power != expected {
+			t.Errorf("SetPowerAndGet expected %v, got %v", expected, power)
+		}
+	}
+}
@@ -0,0 +1,255 @@
+package lifxlan
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+)
+
+// Default values for ProbeOptions.
+const (
+	DefaultProbeConcurrency = 64
+	DefaultProbeTimeout     = time.Second
+)
+
+// MaxScanAddrs is the max number of addresses ScanCIDR probes.
+const MaxScanAddrs = 1 << 16
+
+// ProbeOptions defines the options used by Probe and ScanCIDR.
+//
+// The zero value uses the defaults for all fields.
+type ProbeOptions struct {
+	// The max number of addresses being probed at the same time.
+	//
+	// Values <= 0 mean DefaultProbeConcurrency.
+	Concurrency int
+
+	// The time to wait for the StateService response from every address,
+	// including all the retransmissions.
+	//
+	// Values <= 0 mean DefaultProbeTimeout.
+	Timeout time.Duration
+
+	// The RetryPolicy used to retransmit GetService to every address within
+	// Timeout.
+	Retry RetryPolicy
+
+	// The Transport used to dial the addresses,
+	// nil means DefaultTransport.
+	Transport Transport
+
+	// The options used to create the found devices.
+	DeviceOptions []DeviceOption
+}
+
+func (opts ProbeOptions) concurrency() int {
+	if opts.Concurrency > 0 {
+		return opts.Concurrency
+	}
+	return DefaultProbeConcurrency
+}
+
+func (opts ProbeOptions) timeout() time.Duration {
+	if opts.Timeout > 0 {
+		return opts.Timeout
+	}
+	return DefaultProbeTimeout
+}
+
+func (opts ProbeOptions) transport() Transport {
+	if opts.Transport != nil {
+		return opts.Transport
+	}
+	return DefaultTransport
+}
+
+// Probe sends unicast GetService to every address in addrs,
+// and returns the devices replied.
+//
+// It's useful when broadcast is filtered in the network,
+// so Discover cannot find the devices.
+//
+// addrs can be either in "host" or "host:port" format.
+// When the port is omitted, DefaultBroadcastPort will be used.
+//
+// Addresses without replies within opts.Timeout are skipped,
+// as well as the ones failed with network errors
+// (e.g. ICMP port unreachable).
+// Devices are deduplicated by their targets,
+// and returned in the same order as addrs.
+//
+// If ctx is done before all the addresses are probed,
+// the devices found so far are returned along with ctx.Err().
+//
+// The sent and received messages are reported to the Tracer from the context.
+func Probe(ctx context.Context, addrs []string, opts ProbeOptions) ([]Device, error) {
+	results := make([]Device, len(addrs))
+	sem := make(chan struct{}, opts.concurrency())
+	var wg sync.WaitGroup
+	for i, addr := range addrs {
+		select {
+		case <-ctx.Done():
+		case sem <- struct{}{}:
+			wg.Add(1)
+			go func(i int, addr string) {
+				defer wg.Done()
+				defer func() {
+					<-sem
+				}()
+				// Errors are expected on addresses without devices.
+				results[i], _ = probe(ctx, addr, opts)
+			}(i, addr)
+		}
+	}
+	wg.Wait()
+
+	var devices []Device
+	seen := make(map[Target]bool)
+	for _, d := range results {
+		if d == nil || seen[d.Target()] {
+			continue
+		}
+		seen[d.Target()] = true
+		devices = append(devices, d)
+	}
+	return devices, ctx.Err()
+}
+
+// probe sends GetService to a single address,
+// and returns the device if it replied.
+func probe(ctx context.Context, addr string, opts ProbeOptions) (Device, error) {
+	if _, _, err := net.SplitHostPort(addr); err != nil {
+		addr = net.JoinHostPort(addr, DefaultBroadcastPort)
+	}
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, opts.timeout())
+	defer cancel()
+
+	conn, err := opts.transport().Dial("udp", addr)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	tracer := TracerFromContext(ctx)
+	source := RandomSource()
+	var found Device
+	err = opts.Retry.Do(ctx, func(ctx context.Context, n int) error {
+		seq := uint8(n)
+		header := RawHeader{
+			Size:     HeaderLength,
+			Tagged:   Tagged,
+			Source:   source,
+			Target:   AllDevices,
+			Sequence: seq,
+			Type:     GetService,
+		}
+		msg, err := header.MarshalBinary()
+		if err != nil {
+			return err
+		}
+		written, err := conn.Write(msg)
+		if err != nil {
+			return err
+		}
+		if written < len(msg) {
+			return fmt.Errorf(
+				"lifxlan.Probe: only wrote %d out of %d bytes: %w",
+				written,
+				len(msg),
+				ErrShortWrite,
+			)
+		}
+		tracer.OnSend(AllDevices, header, nil)
+
+		for {
+			resp, err := ReadNextResponse(ctx, conn)
+			if err != nil {
+				return err
+			}
+			if resp.Source != source {
+				tracer.OnDrop(DropWrongSource, resp)
+				continue
+			}
+			if resp.Message != StateService {
+				tracer.OnDrop(DropWrongType, resp)
+				continue
+			}
+			var raw RawStateServicePayload
+			if err := raw.UnmarshalBinary(resp.Payload); err != nil {
+				return err
+			}
+			if raw.Service != ServiceUDP {
+				tracer.OnDrop(DropUnknownService, resp)
+				continue
+			}
+			found = NewDevice(
+				net.JoinHostPort(host, fmt.Sprintf("%d", raw.Port)),
+				raw.Service,
+				resp.Target,
+				opts.DeviceOptions...,
+			)
+			return nil
+		}
+	})
+	return found, err
+}
+
+// ScanCIDR probes all the host addresses in an IPv4 CIDR range,
+// e.g. "10.2.0.0/22",
+// and returns the devices replied.
+//
+// The network and broadcast addresses of the range are not probed,
+// unless the prefix length is 31 or 32.
+// Ranges with more than MaxScanAddrs addresses are rejected.
+//
+// See Probe for more details.
+func ScanCIDR(ctx context.Context, cidr string, opts ProbeOptions) ([]Device, error) {
+	addrs, err := cidrHosts(cidr)
+	if err != nil {
+		return nil, err
+	}
+	return Probe(ctx, addrs, opts)
+}
+
+// cidrHosts returns all the host addresses in an IPv4 CIDR range.
+func cidrHosts(cidr string) ([]string, error) {
+	_, n, err := net.ParseCIDR(cidr)
+	if err != nil {
+		return nil, err
+	}
+	ip := n.IP.To4()
+	ones, bits := n.Mask.Size()
+	if ip == nil || bits != 32 {
+		return nil, fmt.Errorf("lifxlan.ScanCIDR: not an IPv4 range: %q", cidr)
+	}
+	size := uint64(1) << uint(bits-ones)
+	if size > MaxScanAddrs {
+		return nil, fmt.Errorf(
+			"lifxlan.ScanCIDR: range %q too large: %d > %d",
+			cidr,
+			size,
+			MaxScanAddrs,
+		)
+	}
+
+	start := uint32(ip[0])<<24 | uint32(ip[1])<<16 | uint32(ip[2])<<8 | uint32(ip[3])
+	first, last := uint64(0), size-1
+	if ones < 31 {
+		// Skip the network and broadcast addresses.
+		first++
+		last--
+	}
+	addrs := make([]string, 0, last-first+1)
+	for i := first; i <= last; i++ {
+		v := start + uint32(i)
+		addrs = append(addrs, net.IPv4(byte(v>>24), byte(v>>16), byte(v>>8), byte(v)).String())
+	}
+	return addrs, nil
+}
@@ -0,0 +1,142 @@
+package lifxlan_test
+
+import (
+	"context"
+	"net"
+	"reflect"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"go.yhsif.com/lifxlan"
+	"go.yhsif.com/lifxlan/mock"
+)
+
+// closedAddr returns a local UDP address without listener.
+func closedAddr(t *testing.T) string {
+	t.Helper()
+
+	conn, err := net.ListenPacket("udp", mock.ListenAddr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := conn.LocalAddr().String()
+	conn.Close()
+	return addr
+}
+
+func TestProbe(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping test in short mode.")
+	}
+
+	const timeout = time.Millisecond * 200
+
+	device, _ := startDiscoverService(t, 0)
+	// A listener never replies.
+	silent, err := net.ListenPacket("udp", mock.ListenAddr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer silent.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout*2)
+	defer cancel()
+
+	devices, err := lifxlan.Probe(
+		ctx,
+		[]string{
+			closedAddr(t),
+			silent.LocalAddr().String(),
+			device.Addr().String(),
+			device.Addr().String(),
+		},
+		lifxlan.ProbeOptions{
+			Timeout: timeout / 2,
+		},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(devices) != 1 {
+		t.Fatalf("Expected 1 device, got %v", devices)
+	}
+	if devices[0].Target() != device.Target() {
+		t.Errorf("Expected target %v, got %v", device.Target(), devices[0].Target())
+	}
+	if devices[0].Addr().String() != device.Addr().String() {
+		t.Errorf("Expected addr %v, got %v", device.Addr(), devices[0].Addr())
+	}
+}
+
+func TestScanCIDR(t *testing.T) {
+	t.Run(
+		"Invalid",
+		func(t *testing.T) {
+			for _, cidr := range []string{
+				"foo",
+				"fe80::/120",
+				"10.0.0.0/8",
+			} {
+				if _, err := lifxlan.ScanCIDR(
+					context.Background(),
+					cidr,
+					lifxlan.ProbeOptions{},
+				); err == nil {
+					t.Errorf("Expected error for %q, got nil", cidr)
+				}
+			}
+		},
+	)
+
+	t.Run(
+		"Scan",
+		func(t *testing.T) {
+			if testing.Short() {
+				t.Skip("skipping test in short mode.")
+			}
+
+			const timeout = time.Millisecond * 200
+
+			device, _ := startDiscoverService(t, 0)
+			closed := closedAddr(t)
+
+			var lock sync.Mutex
+			var dialed []string
+			transport := lifxlan.TransportFunc(func(network, address string) (net.Conn, error) {
+				lock.Lock()
+				dialed = append(dialed, address)
+				lock.Unlock()
+				// Only 127.0.0.1 has a device.
+				if address == "127.0.0.1:"+lifxlan.DefaultBroadcastPort {
+					return net.Dial(network, device.Addr().String())
+				}
+				return net.Dial(network, closed)
+			})
+
+			ctx, cancel := context.WithTimeout(context.Background(), timeout*2)
+			defer cancel()
+
+			devices, err := lifxlan.ScanCIDR(ctx, "127.0.0.0/30", lifxlan.ProbeOptions{
+				Timeout:   timeout / 2,
+				Transport: transport,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			sort.Strings(dialed)
+			expected := []string{
+				"127.0.0.1:" + lifxlan.DefaultBroadcastPort,
+				"127.0.0.2:" + lifxlan.DefaultBroadcastPort,
+			}
+			if !reflect.DeepEqual(dialed, expected) {
+				t.Errorf("Expected dialed %v, got %v", expected, dialed)
+			}
+			if len(devices) != 1 || devices[0].Addr().String() != device.Addr().String() {
+				t.Errorf("Expected device at %v, got %v", device.Addr(), devices)
+			}
+		},
+	)
+}
@@ -0,0 +1,151 @@
+package lifxlan
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// RateLimitPolicy defines the behavior when the rate limit is reached.
+type RateLimitPolicy int
+
+// RateLimitPolicy values.
+const (
+	// RateLimitWait blocks until the message can be sent,
+	// or the context is cancelled.
+	RateLimitWait RateLimitPolicy = iota
+
+	// RateLimitFailFast returns ErrRateLimited immediately.
+	RateLimitFailFast
+)
+
+// ErrRateLimited is the error returned by Send when the rate limit is reached
+// and the policy is RateLimitFailFast.
+var ErrRateLimited = errors.New("lifxlan: rate limit reached")
+
+// RateLimit defines the parameters of a token bucket rate limiter.
+type RateLimit struct {
+	// Messages allowed per second.
+	//
+	// Values <= 0 mean unlimited.
+	PerSecond float64
+
+	// Max number of messages can be sent at once.
+	//
+	// Values < 1 are treated as 1.
+	Burst int
+
+	// What to do when the rate limit is reached.
+	Policy RateLimitPolicy
+}
+
+// DefaultRateLimit is the rate limit recommended by the LIFX LAN docs,
+// which is no more than 20 messages per second per device.
+var DefaultRateLimit = RateLimit{
+	PerSecond: 20,
+	Burst:     1,
+}
+
+// ProductRateLimits defines rate limits for specific products,
+// keyed by ProductMapKey.
+//
+// It's empty by default.
+var ProductRateLimits = make(map[uint64]RateLimit)
+
+// RateLimitFor returns the rate limit for the product of the hardware version,
+// defined in ProductRateLimits.
+//
+// If the product is not in ProductRateLimits, fallback will be returned.
+func RateLimitFor(version HardwareVersion, fallback RateLimit) RateLimit {
+	if limit, ok := ProductRateLimits[version.ProductMapKey()]; ok {
+		return limit
+	}
+	return fallback
+}
+
+// RateLimiter is a token bucket rate limiter.
+//
+// It's safe for concurrent use.
+type RateLimiter struct {
+	limit RateLimit
+
+	lock   sync.Mutex
+	tokens float64
+	last   time.Time
+}
+
+// NewRateLimiter creates a new RateLimiter with full bucket.
+func NewRateLimiter(limit RateLimit) *RateLimiter {
+	if limit.Burst < 1 {
+		limit.Burst = 1
+	}
+	return &RateLimiter{
+		limit:  limit,
+		tokens: float64(limit.Burst),
+		last:   time.Now(),
+	}
+}
+
+// Limit returns the RateLimit used by this limiter.
+func (l *RateLimiter) Limit() RateLimit {
+	return l.limit
+}
+
+// Wait takes a token from the bucket,
+// following the policy when the bucket is empty.
+//
+// It's safe to call Wait on nil RateLimiter, which never limits.
+func (l *RateLimiter) Wait(ctx context.Context) error {
+	if l == nil || l.limit.PerSecond <= 0 {
+		return nil
+	}
+
+	l.lock.Lock()
+	now := time.Now()
+	l.tokens += now.Sub(l.last).Seconds() * l.limit.PerSecond
+	if burst := float64(l.limit.Burst); l.tokens > burst {
+		l.tokens = burst
+	}
+	l.last = now
+	if l.tokens < 1 && l.limit.Policy == RateLimitFailFast {
+		l.lock.Unlock()
+		return ErrRateLimited
+	}
+	// Reserve the token, the bucket could go negative.
+	l.tokens--
+	wait := time.Duration(-l.tokens / l.limit.PerSecond * float64(time.Second))
+	l.lock.Unlock()
+
+	if wait <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		// Give the reserved token back.
+		l.lock.Lock()
+		l.tokens++
+		l.lock.Unlock()
+		return ctx.Err()
+	}
+}
+
+// rateLimiterHolder wraps *RateLimiter to be stored in atomic.Value.
+type rateLimiterHolder struct {
+	limiter *RateLimiter
+}
+
+func (d *device) RateLimiter() *RateLimiter {
+	if holder, ok := d.limiter.Load().(rateLimiterHolder); ok {
+		return holder.limiter
+	}
+	return nil
+}
+
+func (d *device) SetRateLimiter(limiter *RateLimiter) {
+	d.limiter.Store(rateLimiterHolder{limiter: limiter})
+}
@@ -0,0 +1,151 @@
+package lifxlan_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"go.yhsif.com/lifxlan"
+	"go.yhsif.com/lifxlan/mock"
+)
+
+func TestRateLimiter(t *testing.T) {
+	const timeout = time.Millisecond * 200
+
+	t.Run(
+		"Nil",
+		func(t *testing.T) {
+			var l *lifxlan.RateLimiter
+			for i := 0; i < 100; i++ {
+				if err := l.Wait(context.Background()); err != nil {
+					t.Fatal(err)
+				}
+			}
+		},
+	)
+
+	t.Run(
+		"Wait",
+		func(t *testing.T) {
+			const (
+				burst     = 2
+				perSecond = 100
+				n         = 6
+			)
+			l := lifxlan.NewRateLimiter(lifxlan.RateLimit{
+				PerSecond: perSecond,
+				Burst:     burst,
+			})
+
+			ctx, cancel := context.WithTimeout(context.Background(), timeout)
+			defer cancel()
+
+			start := time.Now()
+			for i := 0; i < n; i++ {
+				if err := l.Wait(ctx); err != nil {
+					t.Fatal(err)
+				}
+			}
+			expected := time.Second * (n - burst) / perSecond
+			if elapsed := time.Since(start); elapsed < expected {
+				t.Errorf("Expected to take at least %v, took %v", expected, elapsed)
+			}
+		},
+	)
+
+	t.Run(
+		"WaitCancelled",
+		func(t *testing.T) {
+			l := lifxlan.NewRateLimiter(lifxlan.RateLimit{
+				PerSecond: 1,
+			})
+
+			ctx, cancel := context.WithTimeout(context.Background(), timeout)
+			defer cancel()
+
+			if err := l.Wait(ctx); err != nil {
+				t.Fatal(err)
+			}
+			if err := l.Wait(ctx); !errors.Is(err, context.DeadlineExceeded) {
+				t.Errorf("Expected context.DeadlineExceeded, got %v", err)
+			}
+		},
+	)
+
+	t.Run(
+		"FailFast",
+		func(t *testing.T) {
+			const burst = 3
+			l := lifxlan.NewRateLimiter(lifxlan.RateLimit{
+				PerSecond: 1,
+				Burst:     burst,
+				Policy:    lifxlan.RateLimitFailFast,
+			})
+
+			for i := 0; i < burst; i++ {
+				if err := l.Wait(context.Background()); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := l.Wait(context.Background()); err != lifxlan.ErrRateLimited {
+				t.Errorf("Expected ErrRateLimited, got %v", err)
+			}
+		},
+	)
+}
+
+func TestRateLimitFor(t *testing.T) {
+	version := lifxlan.HardwareVersion{
+		VendorID:  1,
+		ProductID: 1,
+	}
+	expected := lifxlan.RateLimit{
+		PerSecond: 10,
+	}
+
+	backup := lifxlan.ProductRateLimits
+	t.Cleanup(func() {
+		lifxlan.ProductRateLimits = backup
+	})
+	lifxlan.ProductRateLimits = map[uint64]lifxlan.RateLimit{
+		version.ProductMapKey(): expected,
+	}
+
+	if got := lifxlan.RateLimitFor(version, lifxlan.DefaultRateLimit); got != expected {
+		t.Errorf("Expected %+v, got %+v", expected, got)
+	}
+	version.ProductID = 2
+	if got := lifxlan.RateLimitFor(version, lifxlan.DefaultRateLimit); got != lifxlan.DefaultRateLimit {
+		t.Errorf("Expected %+v, got %+v", lifxlan.DefaultRateLimit, got)
+	}
+}
+
+func TestSendRateLimited(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping test in short mode.")
+	}
+
+	_, device := mock.StartService(t)
+	if device.RateLimiter() != nil {
+		t.Error("Expected no rate limiter by default")
+	}
+	device.SetRateLimiter(lifxlan.NewRateLimiter(lifxlan.RateLimit{
+		PerSecond: 1,
+		Policy:    lifxlan.RateLimitFailFast,
+	}))
+
+	conn, err := device.Dial()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	ctx := context.Background()
+	if _, err := device.Send(ctx, conn, 0, lifxlan.GetPower, nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := device.Send(ctx, conn, 0, lifxlan.GetPower, nil); err != lifxlan.ErrRateLimited {
+		t.Errorf("Expected ErrRateLimited, got %v", err)
+	}
+}
@@ -0,0 +1,106 @@
+package lifxlan
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultAutoRefreshTimeout is the timeout used by the Refresh calls
+// triggered by WithAutoRefresh option.
+const DefaultAutoRefreshTimeout = time.Second * 3
+
+// WithRefreshOptions is a DeviceOption to set the DiscoverOptions used by
+// Device.Refresh.
+//
+// opts.DeviceOptions and opts.QuietPeriod are ignored.
+func WithRefreshOptions(opts DiscoverOptions) DeviceOption {
+	return func(d *device) {
+		d.refreshOpts = opts
+	}
+}
+
+// WithAutoRefresh is a DeviceOption to call Device.Refresh automatically in
+// the background after every after consecutive lost messages
+// (see Stats.ConsecutiveLost),
+// e.g. when the API calls are timing out because the device got a new address.
+//
+// The automatic Refresh uses DefaultAutoRefreshTimeout,
+// and its failures are logged to the Logger of the device's Config.
+// There's at most one automatic Refresh running on the same device.
+//
+// Values of after <= 0 disable the automatic Refresh, which is the default.
+func WithAutoRefresh(after int) DeviceOption {
+	return func(d *device) {
+		d.refreshAfter = after
+		if after > 0 {
+			d.stats.onLost = d.lost
+		} else {
+			d.stats.onLost = nil
+		}
+	}
+}
+
+func (d *device) Refresh(ctx context.Context) error {
+	ctx = WithTracer(ctx, d.Tracer())
+	ctx = ContextWithConfig(ctx, d.Config())
+
+	discoverCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	opts := d.refreshOpts
+	opts.DeviceOptions = nil
+	devices := make(chan Device)
+	errChan := make(chan error, 1)
+	go func() {
+		errChan <- discover(discoverCtx, devices, opts, d.target)
+	}()
+
+	found, ok := <-devices
+	cancel()
+	for range devices {
+		// Drain the channel so discover can return.
+	}
+	err := <-errChan
+	if ok {
+		addr := found.Addr().String()
+		if old := d.address(); old != addr {
+			ConfigFromContext(ctx).Logf(
+				"lifxlan.Device.Refresh: %v moved from %s to %s",
+				d.target,
+				old,
+				addr,
+			)
+			d.addr.Store(addr)
+		}
+		return nil
+	}
+	if err == context.DeadlineExceeded {
+		return fmt.Errorf("lifxlan.Device.Refresh: %w: %v", ErrDeviceNotFound, d.target)
+	}
+	return err
+}
+
+// lost is the LinkStats callback used by WithAutoRefresh.
+func (d *device) lost(consecutive uint64) {
+	if consecutive%uint64(d.refreshAfter) != 0 {
+		return
+	}
+	if !atomic.CompareAndSwapInt32(&d.refreshing, 0, 1) {
+		return
+	}
+	go func() {
+		defer atomic.StoreInt32(&d.refreshing, 0)
+
+		ctx, cancel := context.WithTimeout(context.Background(), DefaultAutoRefreshTimeout)
+		defer cancel()
+		if err := d.Refresh(ctx); err != nil {
+			ConfigFromContext(ContextWithConfig(ctx, d.Config())).Logf(
+				"lifxlan.Device: auto refresh of %v failed: %v",
+				d.target,
+				err,
+			)
+		}
+	}()
+}
@@ -0,0 +1,125 @@
+package lifxlan_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"go.yhsif.com/lifxlan"
+	"go.yhsif.com/lifxlan/mock"
+)
+
+func TestRefresh(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping test in short mode.")
+	}
+
+	const timeout = time.Millisecond * 200
+
+	t.Run(
+		"Refresh",
+		func(t *testing.T) {
+			mockDevice, _ := startDiscoverService(t, 0)
+			device := lifxlan.NewDevice(
+				closedAddr(t),
+				lifxlan.ServiceUDP,
+				mock.Target,
+				lifxlan.WithRefreshOptions(lifxlan.DiscoverOptions{
+					BroadcastHosts: []string{mockDevice.Addr().String()},
+				}),
+			)
+			if err := device.Label().Set("foo"); err != nil {
+				t.Fatal(err)
+			}
+			source := device.Source()
+
+			ctx, cancel := context.WithTimeout(context.Background(), timeout)
+			defer cancel()
+			if err := device.Refresh(ctx); err != nil {
+				t.Fatal(err)
+			}
+			if device.Addr().String() != mockDevice.Addr().String() {
+				t.Errorf("Expected addr %v, got %v", mockDevice.Addr(), device.Addr())
+			}
+			if label := device.Label().String(); label != "foo" {
+				t.Errorf("Expected label %q to be kept, got %q", "foo", label)
+			}
+			if device.Source() != source {
+				t.Errorf("Expected source %d to be kept, got %d", source, device.Source())
+			}
+		},
+	)
+
+	t.Run(
+		"NotFound",
+		func(t *testing.T) {
+			mockDevice, received := startDiscoverService(t, 0)
+			addr := closedAddr(t)
+			device := lifxlan.NewDevice(
+				addr,
+				lifxlan.ServiceUDP,
+				mock.Target+1,
+				lifxlan.WithRefreshOptions(lifxlan.DiscoverOptions{
+					BroadcastHosts: []string{mockDevice.Addr().String()},
+				}),
+			)
+
+			ctx, cancel := context.WithTimeout(context.Background(), timeout)
+			defer cancel()
+			if err := device.Refresh(ctx); !errors.Is(err, lifxlan.ErrDeviceNotFound) {
+				t.Errorf("Expected %v, got %v", lifxlan.ErrDeviceNotFound, err)
+			}
+			if n := received(); n != 1 {
+				t.Errorf("Expected 1 GetService, got %d", n)
+			}
+			if device.Addr().String() != addr {
+				t.Errorf("Expected addr %v to be kept, got %v", addr, device.Addr())
+			}
+		},
+	)
+
+	t.Run(
+		"Auto",
+		func(t *testing.T) {
+			mockDevice, _ := startDiscoverService(t, 0)
+			device := lifxlan.NewDevice(
+				closedAddr(t),
+				lifxlan.ServiceUDP,
+				mock.Target,
+				lifxlan.WithRefreshOptions(lifxlan.DiscoverOptions{
+					BroadcastHosts: []string{mockDevice.Addr().String()},
+				}),
+				lifxlan.WithAutoRefresh(2),
+			)
+
+			for i := 0; i < 2; i++ {
+				ctx, cancel := context.WithTimeout(context.Background(), timeout)
+				defer cancel()
+				if err := device.Echo(ctx, nil, nil); err == nil {
+					t.Fatal("Expected error on the stale address")
+				}
+			}
+			if n := device.Stats().ConsecutiveLost; n != 2 {
+				t.Errorf("Expected 2 consecutive lost, got %d", n)
+			}
+
+			deadline := time.Now().Add(timeout)
+			for device.Addr().String() != mockDevice.Addr().String() {
+				if time.Now().After(deadline) {
+					t.Fatalf("Expected addr %v after auto refresh, got %v", mockDevice.Addr(), device.Addr())
+				}
+				time.Sleep(time.Millisecond * 5)
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), timeout)
+			defer cancel()
+			if err := device.Echo(ctx, nil, nil); err != nil {
+				t.Fatal(err)
+			}
+			if n := device.Stats().ConsecutiveLost; n != 0 {
+				t.Errorf("Expected 0 consecutive lost after a reply, got %d", n)
+			}
+		},
+	)
+}
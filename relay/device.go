@@ -32,6 +32,13 @@ type Device interface {
 	// this function will only return nil error after it received ack from the
 	// device.
 	SetRPower(ctx context.Context, conn net.Conn, index uint8, power lifxlan.Power, ack bool) error
+	// SetRPowerAndGet is similar to SetRPower,
+	// but requests the device to reply with the resulting power level of the
+	// relay at index, and returns it.
+	//
+	// The message will be retransmitted according to RetryPolicy() until the
+	// response is received.
+	SetRPowerAndGet(ctx context.Context, conn net.Conn, index uint8, power lifxlan.Power) (lifxlan.Power, error)
 }
 
 type device struct {
@@ -4,9 +4,15 @@ import (
 	"go.yhsif.com/lifxlan"
 )
 
-// Tile related MessageType values.
+// Relay related MessageType values.
 const (
 	GetRPower   lifxlan.MessageType = 816
 	SetRPower   lifxlan.MessageType = 817
 	StateRPower lifxlan.MessageType = 818
 )
+
+func init() {
+	lifxlan.RegisterMessage(GetRPower, "relay.GetRPower", RawGetRPowerPayload{})
+	lifxlan.RegisterMessage(SetRPower, "relay.SetRPower", RawSetRPowerPayload{})
+	lifxlan.RegisterMessage(StateRPower, "relay.StateRPower", RawStateRPowerPayload{})
+}
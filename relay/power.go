@@ -1,9 +1,9 @@
 package relay
 
 import (
-	"bytes"
 	"context"
 	"encoding/binary"
+	"io"
 	"net"
 
 	"go.yhsif.com/lifxlan"
@@ -16,6 +16,25 @@ type RawGetRPowerPayload struct {
 	Index uint8
 }
 
+// MarshalBinary implements encoding.BinaryMarshaler.
+func (p *RawGetRPowerPayload) MarshalBinary() ([]byte, error) {
+	return p.AppendBinary(make([]byte, 0, 1))
+}
+
+// AppendBinary implements lifxlan.BinaryAppender.
+func (p *RawGetRPowerPayload) AppendBinary(b []byte) ([]byte, error) {
+	return append(b, p.Index), nil
+}
+
+// UnmarshalBinary implements encoding.BinaryUnmarshaler.
+func (p *RawGetRPowerPayload) UnmarshalBinary(data []byte) error {
+	if len(data) < 1 {
+		return io.ErrUnexpectedEOF
+	}
+	p.Index = data[0]
+	return nil
+}
+
 // RawStateRPowerPayload defines the struct to be used for encoding and decoding.
 //
 // https://lan.developer.lifx.com/docs/information-messages#staterpower---packet-818
@@ -24,57 +43,45 @@ type RawStateRPowerPayload struct {
 	Level lifxlan.Power
 }
 
-func (rd *device) GetRPower(ctx context.Context, conn net.Conn, index uint8) (lifxlan.Power, error) {
-	if ctx.Err() != nil {
-		return 0, ctx.Err()
-	}
+// MarshalBinary implements encoding.BinaryMarshaler.
+func (p *RawStateRPowerPayload) MarshalBinary() ([]byte, error) {
+	return p.AppendBinary(make([]byte, 0, 3))
+}
+
+// AppendBinary implements lifxlan.BinaryAppender.
+func (p *RawStateRPowerPayload) AppendBinary(b []byte) ([]byte, error) {
+	var buf [3]byte
+	buf[0] = p.Index
+	binary.LittleEndian.PutUint16(buf[1:], uint16(p.Level))
+	return append(b, buf[:]...), nil
+}
 
-	if conn == nil {
-		newConn, err := rd.Dial()
-		if err != nil {
-			return 0, err
-		}
-		defer newConn.Close()
-		conn = newConn
-
-		if ctx.Err() != nil {
-			return 0, ctx.Err()
-		}
+// UnmarshalBinary implements encoding.BinaryUnmarshaler.
+func (p *RawStateRPowerPayload) UnmarshalBinary(data []byte) error {
+	if len(data) < 3 {
+		return io.ErrUnexpectedEOF
 	}
+	p.Index = data[0]
+	p.Level = lifxlan.Power(binary.LittleEndian.Uint16(data[1:]))
+	return nil
+}
 
-	seq, err := rd.Send(
+func (rd *device) GetRPower(ctx context.Context, conn net.Conn, index uint8) (lifxlan.Power, error) {
+	var raw RawStateRPowerPayload
+	if err := lifxlan.Request(
 		ctx,
+		rd,
 		conn,
-		0, // flags
 		GetRPower,
 		&RawGetRPowerPayload{
 			Index: index,
 		},
-	)
-	if err != nil {
+		StateRPower,
+		&raw,
+	); err != nil {
 		return 0, err
 	}
-
-	for {
-		resp, err := lifxlan.ReadNextResponse(ctx, conn)
-		if err != nil {
-			return 0, err
-		}
-		if resp.Sequence != seq || resp.Source != rd.Source() {
-			continue
-		}
-		if resp.Message != StateRPower {
-			continue
-		}
-
-		var raw RawStateRPowerPayload
-		r := bytes.NewReader(resp.Payload)
-		if err := binary.Read(r, binary.LittleEndian, &raw); err != nil {
-			return 0, err
-		}
-
-		return raw.Level, nil
-	}
+	return raw.Level, nil
 }
 
 // RawSetRPowerPayload defines the struct to be used for encoding and decoding.
@@ -85,6 +92,29 @@ type RawSetRPowerPayload struct {
 	Level lifxlan.Power
 }
 
+// MarshalBinary implements encoding.BinaryMarshaler.
+func (p *RawSetRPowerPayload) MarshalBinary() ([]byte, error) {
+	return p.AppendBinary(make([]byte, 0, 3))
+}
+
+// AppendBinary implements lifxlan.BinaryAppender.
+func (p *RawSetRPowerPayload) AppendBinary(b []byte) ([]byte, error) {
+	var buf [3]byte
+	buf[0] = p.Index
+	binary.LittleEndian.PutUint16(buf[1:], uint16(p.Level))
+	return append(b, buf[:]...), nil
+}
+
+// UnmarshalBinary implements encoding.BinaryUnmarshaler.
+func (p *RawSetRPowerPayload) UnmarshalBinary(data []byte) error {
+	if len(data) < 3 {
+		return io.ErrUnexpectedEOF
+	}
+	p.Index = data[0]
+	p.Level = lifxlan.Power(binary.LittleEndian.Uint16(data[1:]))
+	return nil
+}
+
 func (rd *device) SetRPower(
 	ctx context.Context,
 	conn net.Conn,
@@ -92,47 +122,39 @@ func (rd *device) SetRPower(
 	power lifxlan.Power,
 	ack bool,
 ) error {
-	if ctx.Err() != nil {
-		return ctx.Err()
-	}
-
-	if conn == nil {
-		newConn, err := rd.Dial()
-		if err != nil {
-			return err
-		}
-		defer newConn.Close()
-		conn = newConn
-
-		if ctx.Err() != nil {
-			return ctx.Err()
-		}
-	}
-
-	var flags lifxlan.AckResFlag
-	if ack {
-		flags |= lifxlan.FlagAckRequired
-	}
-
-	seq, err := rd.Send(
+	return lifxlan.Command(
 		ctx,
+		rd,
 		conn,
-		flags,
 		SetRPower,
 		&RawSetRPowerPayload{
 			Index: index,
 			Level: power,
 		},
+		ack,
 	)
-	if err != nil {
-		return err
-	}
+}
 
-	if ack {
-		if err := lifxlan.WaitForAcks(ctx, conn, rd.Source(), seq); err != nil {
-			return err
-		}
+func (rd *device) SetRPowerAndGet(
+	ctx context.Context,
+	conn net.Conn,
+	index uint8,
+	power lifxlan.Power,
+) (lifxlan.Power, error) {
+	var raw RawStateRPowerPayload
+	if err := lifxlan.SetRequest(
+		ctx,
+		rd,
+		conn,
+		SetRPower,
+		&RawSetRPowerPayload{
+			Index: index,
+			Level: power,
+		},
+		StateRPower,
+		&raw,
+	); err != nil {
+		return 0, err
 	}
-
-	return nil
+	return raw.Level, nil
 }
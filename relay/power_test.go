@@ -118,4 +118,15 @@ func TestSetRPower(t *testing.T) {
 	if !called {
 		t.Error("SetRPower message not received.")
 	}
+
+	delete(service.Handlers, relay.SetRPower)
+	for _, expected := range []lifxlan.Power{lifxlan.PowerOn, lifxlan.PowerOff} {
+		power, err := rd.SetRPowerAndGet(ctx, nil, index, expected)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if power != expected {
+			t.Errorf("SetRPowerAndGet expected %v, got %v", expected, power)
+		}
+	}
 }
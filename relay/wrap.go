@@ -1,9 +1,7 @@
 package relay
 
 import (
-	"bytes"
 	"context"
-	"encoding/binary"
 
 	"go.yhsif.com/lifxlan"
 )
@@ -28,57 +26,21 @@ func Wrap(ctx context.Context, d lifxlan.Device, force bool) (Device, error) {
 		}
 	}
 
-	conn, err := d.Dial()
-	if err != nil {
-		return nil, err
-	}
-	defer conn.Close()
-
-	if ctx.Err() != nil {
-		return nil, ctx.Err()
-	}
-
-	seq, err := d.Send(
+	if err := lifxlan.Request(
 		ctx,
-		conn,
-		0, // flags
+		d,
+		nil, // conn
 		GetRPower,
 		&RawGetRPowerPayload{
 			Index: 0,
 		},
-	)
-	if err != nil {
+		StateRPower,
+		nil, // out
+	); err != nil {
 		return nil, err
 	}
 
-	for {
-		resp, err := lifxlan.ReadNextResponse(ctx, conn)
-		if err != nil {
-			return nil, err
-		}
-		if resp.Sequence != seq || resp.Source != d.Source() {
-			continue
-		}
-
-		switch resp.Message {
-		case StateRPower:
-			var raw RawStateRPowerPayload
-			r := bytes.NewReader(resp.Payload)
-			if err := binary.Read(r, binary.LittleEndian, &raw); err != nil {
-				return nil, err
-			}
-
-			return &device{
-				Device: d,
-			}, nil
-
-		case lifxlan.StateUnhandled:
-			var raw lifxlan.RawStateUnhandledPayload
-			r := bytes.NewReader(resp.Payload)
-			if err := binary.Read(r, binary.LittleEndian, &raw); err != nil {
-				return nil, err
-			}
-			return nil, raw
-		}
-	}
+	return &device{
+		Device: d,
+	}, nil
 }
@@ -0,0 +1,372 @@
+package lifxlan
+
+import (
+	"bytes"
+	"context"
+	"encoding"
+	"encoding/binary"
+	"net"
+	"sync"
+)
+
+// ResponseHandler handles a matched response in RequestFunc.
+//
+// It returns whether the request is done (no more responses expected).
+// If it returns a non-nil error, RequestFunc returns the error immediately.
+type ResponseHandler func(resp *Response) (done bool, err error)
+
+// Request sends a message to a device and decodes the expected response.
+//
+// It's the round-trip primitive used by all the GetFoo() device APIs,
+// and can be used to implement missing device APIs.
+//
+// If conn is nil,
+// a new connection will be made and guaranteed to be closed before returning.
+//
+// The message will be retransmitted according to d.RetryPolicy().
+// Only responses with d's source, the sequence of one of the sent messages,
+// and the expect message type are matched.
+// Matched StateUnhandled responses are returned as *UnhandledError.
+//
+// The payload of the first matched response will be decoded into out,
+// which should be a pointer to the payload struct,
+// or nil if the payload is not needed.
+func Request(
+	ctx context.Context,
+	d Device,
+	conn net.Conn,
+	message MessageType,
+	payload interface{},
+	expect MessageType,
+	out interface{},
+) error {
+	return RequestFunc(
+		ctx,
+		d,
+		conn,
+		message,
+		payload,
+		expect,
+		func(resp *Response) (bool, error) {
+			if out == nil {
+				return true, nil
+			}
+			return true, resp.DecodePayload(out)
+		},
+	)
+}
+
+// SetRequest is similar to Request,
+// but sends the message with FlagResRequired.
+//
+// It's the primitive used by all the SetFooAndGet() device APIs.
+// Set messages don't cause responses unless FlagResRequired is set,
+// in which case the device replies with the matching State message.
+func SetRequest(
+	ctx context.Context,
+	d Device,
+	conn net.Conn,
+	message MessageType,
+	payload interface{},
+	expect MessageType,
+	out interface{},
+) error {
+	return requestFunc(
+		ctx,
+		d,
+		conn,
+		FlagResRequired,
+		message,
+		payload,
+		expect,
+		func(resp *Response) (bool, error) {
+			if out == nil {
+				return true, nil
+			}
+			return true, resp.DecodePayload(out)
+		},
+	)
+}
+
+// RequestFunc is similar to Request,
+// but calls handler for every matched response until it returns done.
+//
+// It's useful when the message causes multiple responses,
+// or when the response needs to be handled in a special way.
+func RequestFunc(
+	ctx context.Context,
+	d Device,
+	conn net.Conn,
+	message MessageType,
+	payload interface{},
+	expect MessageType,
+	handler ResponseHandler,
+) error {
+	return requestFunc(
+		ctx,
+		d,
+		conn,
+		0, // flags
+		message,
+		payload,
+		expect,
+		handler,
+	)
+}
+
+func requestFunc(
+	ctx context.Context,
+	d Device,
+	conn net.Conn,
+	flags AckResFlag,
+	message MessageType,
+	payload interface{},
+	expect MessageType,
+	handler ResponseHandler,
+) error {
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	if conn == nil {
+		newConn, err := d.Dial()
+		if err != nil {
+			return err
+		}
+		defer newConn.Close()
+		conn = newConn
+
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+	}
+
+	ctx = WithTracer(ctx, d.Tracer())
+	ctx = ContextWithConfig(ctx, d.Config())
+	tracer := TracerFromContext(ctx)
+	replies := newReplyTracker(d, false)
+	defer replies.finish()
+	return d.RetryPolicy().Do(ctx, func(ctx context.Context, _ int) error {
+		// Get messages cause responses without FlagResRequired.
+		seq, err := d.Send(
+			ctx,
+			conn,
+			flags,
+			message,
+			payload,
+			ExpectReply(),
+		)
+		if err != nil {
+			return err
+		}
+		replies.sent(seq, true) // acquired with ExpectReply
+
+		for {
+			resp, err := ReadNextResponse(ctx, conn)
+			if err != nil {
+				// Only fail on StateUnhandled or invalid replies to the sent
+				// messages.
+				resp = ErrorResponse(err)
+				if resp == nil {
+					return err
+				}
+			}
+			if resp.Source != d.Source() {
+				tracer.OnDrop(DropWrongSource, resp)
+				continue
+			}
+			if err == nil && resp.Message != expect {
+				tracer.OnDrop(DropWrongType, resp)
+				continue
+			}
+			if !replies.received(resp.Sequence) {
+				tracer.OnDrop(DropWrongSequence, resp)
+				continue
+			}
+			if err != nil {
+				return err
+			}
+
+			done, err := handler(resp)
+			if err != nil || done {
+				return err
+			}
+		}
+	})
+}
+
+// Command sends a message that doesn't cause a response to a device.
+//
+// It's the primitive used by all the SetFoo() device APIs,
+// and can be used to implement missing device APIs.
+//
+// If conn is nil,
+// a new connection will be made and guaranteed to be closed before returning.
+//
+// If ack is false,
+// this function returns nil error after the message is sent successfully.
+// If ack is true,
+// this function will only return nil error after it received ack from the
+// device,
+// and the message will be retransmitted according to d.RetryPolicy().
+func Command(
+	ctx context.Context,
+	d Device,
+	conn net.Conn,
+	message MessageType,
+	payload interface{},
+	ack bool,
+) error {
+	return Commands(ctx, d, conn, message, []interface{}{payload}, ack)
+}
+
+// Commands is similar to Command,
+// but sends multiple messages of the same type concurrently,
+// e.g. one for every tile in a device chain.
+//
+// If ack is true,
+// this function will only return nil error after it received ack for every
+// message,
+// and only the messages not acked yet will be retransmitted according to
+// d.RetryPolicy().
+func Commands(
+	ctx context.Context,
+	d Device,
+	conn net.Conn,
+	message MessageType,
+	payloads []interface{},
+	ack bool,
+) error {
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	if conn == nil {
+		newConn, err := d.Dial()
+		if err != nil {
+			return err
+		}
+		defer newConn.Close()
+		conn = newConn
+
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+	}
+
+	if !ack {
+		_, err := sendAll(ctx, d, conn, 0, message, payloads)
+		return err
+	}
+	if len(payloads) == 0 {
+		return nil
+	}
+
+	ctx = WithTracer(ctx, d.Tracer())
+	ctx = ContextWithConfig(ctx, d.Config())
+	replies := newReplyTracker(d, true)
+	defer replies.finish()
+	// The index of the payload for every sent sequence.
+	groups := make(map[uint8]int)
+	acked := make([]bool, len(payloads))
+	var seqs []uint8
+	return d.RetryPolicy().Do(ctx, func(ctx context.Context, _ int) error {
+		// Only resend the payloads not acked yet.
+		var pending []interface{}
+		var indices []int
+		for i, payload := range payloads {
+			if !acked[i] {
+				pending = append(pending, payload)
+				indices = append(indices, i)
+			}
+		}
+		sent, err := sendAll(ctx, d, conn, FlagAckRequired, message, pending)
+		if err != nil {
+			return err
+		}
+		for i, seq := range sent {
+			replies.sent(seq, true) // acquired with FlagAckRequired
+			groups[seq] = indices[i]
+		}
+		seqs = append(seqs, sent...)
+
+		// Acks to the earlier retransmissions are still good.
+		waiting := make(map[uint8]int)
+		for seq, i := range groups {
+			if !acked[i] {
+				waiting[seq] = i
+			}
+		}
+		return waitForAcks(
+			ctx,
+			conn,
+			d.Source(),
+			seqs,
+			waiting,
+			func(seq uint8) {
+				replies.received(seq)
+				acked[groups[seq]] = true
+			},
+		)
+	})
+}
+
+// sendAll sends all the payloads concurrently,
+// and returns their sequences in the same order.
+//
+// If any of them fails,
+// the acquired sequences of the successful ones are released.
+func sendAll(
+	ctx context.Context,
+	d Device,
+	conn net.Conn,
+	flags AckResFlag,
+	message MessageType,
+	payloads []interface{},
+) ([]uint8, error) {
+	seqs := make([]uint8, len(payloads))
+	errs := make([]error, len(payloads))
+	var wg sync.WaitGroup
+	wg.Add(len(payloads))
+	for i, payload := range payloads {
+		go func(i int, payload interface{}) {
+			defer wg.Done()
+			seqs[i], errs[i] = d.Send(
+				ctx,
+				conn,
+				flags,
+				message,
+				payload,
+			)
+		}(i, payload)
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			// Release the acquired sequences of the successful ones,
+			// as the caller won't see them.
+			if expectsReply(flags) {
+				for i := range seqs {
+					if errs[i] == nil {
+						d.ReleaseSequence(seqs[i])
+					}
+				}
+			}
+			return nil, err
+		}
+	}
+	return seqs, nil
+}
+
+// DecodePayload decodes the payload of the response into out,
+// which should be a pointer to the payload struct.
+//
+// If out implements encoding.BinaryUnmarshaler, it will be used.
+// Otherwise it falls back to encoding/binary with LittleEndian.
+func (r *Response) DecodePayload(out interface{}) error {
+	if u, ok := out.(encoding.BinaryUnmarshaler); ok {
+		return u.UnmarshalBinary(r.Payload)
+	}
+	return binary.Read(bytes.NewReader(r.Payload), binary.LittleEndian, out)
+}
@@ -0,0 +1,294 @@
+package lifxlan_test
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"go.yhsif.com/lifxlan"
+	"go.yhsif.com/lifxlan/mock"
+)
+
+func TestRequest(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping test in short mode.")
+	}
+
+	const timeout = time.Millisecond * 200
+
+	service, device := mock.StartService(t)
+
+	t.Run(
+		"Normal",
+		func(t *testing.T) {
+			const expected = lifxlan.PowerOn
+			service.RawStatePowerPayload = &lifxlan.RawStatePowerPayload{
+				Level: expected,
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), timeout)
+			defer cancel()
+
+			var raw lifxlan.RawStatePowerPayload
+			if err := lifxlan.Request(
+				ctx,
+				device,
+				nil, // conn
+				lifxlan.GetPower,
+				nil, // payload
+				lifxlan.StatePower,
+				&raw,
+			); err != nil {
+				t.Fatal(err)
+			}
+			if raw.Level != expected {
+				t.Errorf("Power expected %v, got %v", expected, raw.Level)
+			}
+		},
+	)
+
+	t.Run(
+		"StateUnhandled",
+		func(t *testing.T) {
+			const msg = lifxlan.GetLabel
+			service.Handlers[msg] = mock.StateUnhandledHandler(msg)
+			defer delete(service.Handlers, msg)
+
+			ctx, cancel := context.WithTimeout(context.Background(), timeout)
+			defer cancel()
+
+			err := lifxlan.Request(
+				ctx,
+				device,
+				nil, // conn
+				msg,
+				nil, // payload
+				lifxlan.StateLabel,
+				nil, // out
+			)
+			var unhandled *lifxlan.UnhandledError
+			if !errors.As(err, &unhandled) {
+				t.Fatalf("Expected *UnhandledError error, got %v", err)
+			}
+			if unhandled.Message != msg {
+				t.Errorf("Expected unhandled type %v, got %v", msg, unhandled.Message)
+			}
+			if unhandled.Target != device.Target() {
+				t.Errorf("Expected target %v, got %v", device.Target(), unhandled.Target)
+			}
+
+			// Backward compatibility.
+			var raw lifxlan.RawStateUnhandledPayload
+			if !errors.As(err, &raw) {
+				t.Fatalf("Expected RawStateUnhandledPayload error, got %v", err)
+			}
+			if raw.UnhandledType != msg {
+				t.Errorf("Expected unhandled type %v, got %v", msg, raw.UnhandledType)
+			}
+		},
+	)
+
+	t.Run(
+		"StaleUnhandled",
+		func(t *testing.T) {
+			// Replies with StateUnhandled not matching the sent message first,
+			// e.g. late replies to earlier calls on a pooled connection.
+			replyStale := func(s *mock.Service, conn net.PacketConn, addr net.Addr, orig *lifxlan.Response) {
+				payload, err := (&lifxlan.RawStateUnhandledPayload{
+					UnhandledType: orig.Message,
+				}).MarshalBinary()
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				wrongSequence := *orig
+				wrongSequence.Sequence--
+				s.Reply(conn, addr, &wrongSequence, lifxlan.StateUnhandled, payload)
+				wrongSource := *orig
+				wrongSource.Source++
+				s.Reply(conn, addr, &wrongSource, lifxlan.StateUnhandled, payload)
+			}
+			service.Handlers[lifxlan.GetPower] = func(
+				s *mock.Service,
+				conn net.PacketConn,
+				addr net.Addr,
+				orig *lifxlan.Response,
+			) {
+				replyStale(s, conn, addr, orig)
+				mock.DefaultHandlerFunc(s, conn, addr, orig)
+			}
+			defer delete(service.Handlers, lifxlan.GetPower)
+			service.Handlers[lifxlan.SetPower] = replyStale
+			defer delete(service.Handlers, lifxlan.SetPower)
+			service.RawStatePowerPayload = &lifxlan.RawStatePowerPayload{
+				Level: lifxlan.PowerOn,
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), timeout)
+			defer cancel()
+
+			if err := lifxlan.Request(
+				ctx,
+				device,
+				nil, // conn
+				lifxlan.GetPower,
+				nil, // payload
+				lifxlan.StatePower,
+				nil, // out
+			); err != nil {
+				t.Errorf("Request: %v", err)
+			}
+			if err := lifxlan.Command(
+				ctx,
+				device,
+				nil, // conn
+				lifxlan.SetPower,
+				&lifxlan.RawSetPowerPayload{
+					Level: lifxlan.PowerOn,
+				},
+				true, // ack
+			); err != nil {
+				t.Errorf("Command: %v", err)
+			}
+		},
+	)
+
+	t.Run(
+		"Commands",
+		func(t *testing.T) {
+			service.AcksToDrop = 1
+			defer func() {
+				service.AcksToDrop = 0
+			}()
+			*device.RetryPolicy() = lifxlan.RetryPolicy{
+				Attempts:       2,
+				AttemptTimeout: timeout / 4,
+			}
+			defer func() {
+				*device.RetryPolicy() = lifxlan.RetryPolicy{}
+			}()
+			device.LinkStats().Reset()
+
+			ctx, cancel := context.WithTimeout(context.Background(), timeout)
+			defer cancel()
+
+			if err := lifxlan.Commands(
+				ctx,
+				device,
+				nil, // conn
+				lifxlan.SetPower,
+				[]interface{}{
+					&lifxlan.RawSetPowerPayload{Level: lifxlan.PowerOn},
+					&lifxlan.RawSetPowerPayload{Level: lifxlan.PowerOff},
+				},
+				true, // ack
+			); err != nil {
+				t.Fatal(err)
+			}
+			// Only the message without ack is resent.
+			expected := lifxlan.LossStats{
+				Sent:     3,
+				Received: 2,
+				Lost:     1,
+			}
+			if s := device.Stats(); s.Acks != expected {
+				t.Errorf("Acks expected %+v, got %+v", expected, s.Acks)
+			}
+		},
+	)
+
+	t.Run(
+		"Func",
+		func(t *testing.T) {
+			const n = 3
+			service.Handlers[lifxlan.GetPower] = func(
+				s *mock.Service,
+				conn net.PacketConn,
+				addr net.Addr,
+				orig *lifxlan.Response,
+			) {
+				for i := 0; i < n; i++ {
+					mock.DefaultHandlerFunc(s, conn, addr, orig)
+				}
+			}
+			defer delete(service.Handlers, lifxlan.GetPower)
+
+			ctx, cancel := context.WithTimeout(context.Background(), timeout)
+			defer cancel()
+
+			var got int
+			if err := lifxlan.RequestFunc(
+				ctx,
+				device,
+				nil, // conn
+				lifxlan.GetPower,
+				nil, // payload
+				lifxlan.StatePower,
+				func(resp *lifxlan.Response) (bool, error) {
+					got++
+					return got >= n, nil
+				},
+			); err != nil {
+				t.Fatal(err)
+			}
+		},
+	)
+}
+
+func TestCommand(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping test in short mode.")
+	}
+
+	const timeout = time.Millisecond * 200
+
+	service, device := mock.StartService(t)
+
+	t.Run(
+		"NoAck",
+		func(t *testing.T) {
+			service.AcksToDrop = 1
+
+			ctx, cancel := context.WithTimeout(context.Background(), timeout)
+			defer cancel()
+
+			if err := lifxlan.Command(
+				ctx,
+				device,
+				nil, // conn
+				lifxlan.SetPower,
+				&lifxlan.RawSetPowerPayload{
+					Level: lifxlan.PowerOn,
+				},
+				true, // ack
+			); err == nil {
+				t.Error("Expected error when not getting ack, got nil")
+			}
+		},
+	)
+
+	t.Run(
+		"Ack",
+		func(t *testing.T) {
+			service.AcksToDrop = 0
+
+			ctx, cancel := context.WithTimeout(context.Background(), timeout)
+			defer cancel()
+
+			if err := lifxlan.Command(
+				ctx,
+				device,
+				nil, // conn
+				lifxlan.SetPower,
+				&lifxlan.RawSetPowerPayload{
+					Level: lifxlan.PowerOn,
+				},
+				true, // ack
+			); err != nil {
+				t.Error(err)
+			}
+		},
+	)
+}
@@ -1,11 +1,9 @@
 package lifxlan
 
 import (
-	"bytes"
 	"context"
-	"encoding/binary"
+	"errors"
 	"fmt"
-	"io/ioutil"
 	"net"
 )
 
@@ -13,42 +11,66 @@ import (
 type Response struct {
 	Message  MessageType
 	Flags    AckResFlag
+	Tagged   bool
 	Source   uint32
 	Target   Target
 	Sequence uint8
 	Payload  []byte
 }
 
+// AckRequired returns whether the ack_required bit is set in the response.
+func (r *Response) AckRequired() bool {
+	return r.Flags.AckRequired()
+}
+
+// ResRequired returns whether the res_required bit is set in the response.
+func (r *Response) ResRequired() bool {
+	return r.Flags.ResRequired()
+}
+
 // ParseResponse parses the response received from a lifxlan device.
+//
+// All the documented invariants of the header are validated,
+// and violations are returned as *HeaderError.
+// StateUnhandled responses are returned as *UnhandledError.
+//
+// The returned Response doesn't reference msg,
+// so it's safe to reuse msg after ParseResponse returns.
 func ParseResponse(msg []byte) (*Response, error) {
 	if len(msg) < int(HeaderLength) {
 		return nil, fmt.Errorf(
-			"lifxlan.ParseResponse: response size not enough: %d < %d",
+			"lifxlan.ParseResponse: response size not enough: %d < %d: %w",
 			len(msg),
 			HeaderLength,
+			ErrSizeMismatch,
 		)
 	}
 
 	var d RawHeader
-	r := bytes.NewReader(msg)
-	if err := binary.Read(r, binary.LittleEndian, &d); err != nil {
+	if err := d.UnmarshalBinary(msg); err != nil {
 		return nil, err
 	}
 	if len(msg) != int(d.Size) {
-		return nil, fmt.Errorf(
-			"lifxlan.ParseResponse: response size mismatch: %d != %d",
-			len(msg),
-			d.Size,
-		)
+		return nil, &HeaderError{
+			Header: d,
+			Err: fmt.Errorf(
+				"response size mismatch: %d != %d: %w",
+				len(msg),
+				d.Size,
+				ErrSizeMismatch,
+			),
+		}
 	}
-
-	payload, err := ioutil.ReadAll(r)
-	if err != nil {
+	if err := d.Validate(); err != nil {
 		return nil, err
 	}
+
+	payload := make([]byte, len(msg)-HeaderLength)
+	copy(payload, msg[HeaderLength:])
 	resp := &Response{
 		Message:  d.Type,
 		Flags:    d.Flags,
+		Tagged:   d.Tagged.IsTagged(),
 		Source:   d.Source,
 		Target:   d.Target,
 		Sequence: d.Sequence,
@@ -57,11 +79,15 @@ func ParseResponse(msg []byte) (*Response, error) {
 
 	if resp.Message == StateUnhandled {
 		var raw RawStateUnhandledPayload
-		r := bytes.NewReader(resp.Payload)
-		if err := binary.Read(r, binary.LittleEndian, &raw); err != nil {
+		if err := raw.UnmarshalBinary(resp.Payload); err != nil {
 			return nil, err
 		}
-		return nil, raw
+		return nil, &UnhandledError{
+			Target:   resp.Target,
+			Message:  raw.UnhandledType,
+			Source:   resp.Source,
+			Sequence: resp.Sequence,
+		}
 	}
 	return resp, nil
 }
@@ -70,14 +96,18 @@ func ParseResponse(msg []byte) (*Response, error) {
 //
 // It handles read buffer, deadline, context cancellation check,
 // and response parsing.
+// The read timeout and buffer size are from the Config from the context.
+// The parsed response will be reported to the Tracer from the context.
 func ReadNextResponse(ctx context.Context, conn net.Conn) (*Response, error) {
-	buf := make([]byte, ResponseReadBufferSize)
+	config := ConfigFromContext(ctx)
+	buf, done := config.readBuffer()
+	defer done()
 	for {
 		if ctx.Err() != nil {
 			return nil, ctx.Err()
 		}
 
-		if err := conn.SetReadDeadline(GetReadDeadline()); err != nil {
+		if err := conn.SetReadDeadline(config.ReadDeadline()); err != nil {
 			return nil, err
 		}
 
@@ -89,6 +119,45 @@ func ReadNextResponse(ctx context.Context, conn net.Conn) (*Response, error) {
 			return nil, err
 		}
 
-		return ParseResponse(buf[:n])
+		resp, err := ParseResponse(buf[:n])
+		if err != nil {
+			return nil, err
+		}
+		TracerFromContext(ctx).OnReceive(resp)
+		return resp, nil
+	}
+}
+
+// ErrorResponse returns the Response with only the header fields of the
+// received message causing err returned by ParseResponse or ReadNextResponse,
+// for the errors carrying the header (*UnhandledError and *HeaderError).
+//
+// It returns nil for other errors.
+//
+// It's useful to check whether the message is a reply to the sent messages,
+// as stale replies (e.g. to earlier API calls on the same pooled connection)
+// should be dropped instead of failing the API call.
+func ErrorResponse(err error) *Response {
+	var unhandled *UnhandledError
+	if errors.As(err, &unhandled) {
+		return &Response{
+			Message:  StateUnhandled,
+			Source:   unhandled.Source,
+			Target:   unhandled.Target,
+			Sequence: unhandled.Sequence,
+		}
+	}
+	var headerErr *HeaderError
+	if errors.As(err, &headerErr) {
+		h := headerErr.Header
+		return &Response{
+			Message:  h.Type,
+			Flags:    h.Flags,
+			Tagged:   h.Tagged.IsTagged(),
+			Source:   h.Source,
+			Target:   h.Target,
+			Sequence: h.Sequence,
+		}
 	}
+	return nil
 }
@@ -0,0 +1,104 @@
+package lifxlan
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// RetryPolicy defines how API calls expecting responses or acks retransmit
+// their messages on packet loss.
+//
+// The zero value means no retransmissions:
+// the message will be sent exactly once and the API call will wait until
+// the context is cancelled.
+//
+// On retransmissions a fresh sequence will be used,
+// but responses or acks to all the previous attempts are still accepted.
+// So the API call returns as soon as any matching reply arrives.
+type RetryPolicy struct {
+	// Max number of attempts, including the first one.
+	//
+	// Values <= 1 mean no retransmissions.
+	Attempts int
+
+	// The time to wait for a reply before retransmitting.
+	//
+	// The last attempt always waits until the context is cancelled.
+	// Values <= 0 mean no retransmissions.
+	AttemptTimeout time.Duration
+
+	// The time to wait before the first retransmission.
+	Backoff time.Duration
+
+	// If BackoffFactor > 1,
+	// Backoff will be multiplied by it after every retransmission.
+	BackoffFactor float64
+
+	// If MaxBackoff > 0, the backoff will be capped at MaxBackoff.
+	MaxBackoff time.Duration
+}
+
+// Do calls attempt following the policy.
+//
+// attempt will be called with a context that's cancelled when the attempt
+// times out, and n, the 0-based index of the attempt.
+// If attempt returns a non-nil error because that context timed out,
+// attempt will be called again after the backoff,
+// unless ctx is cancelled or it's already the last attempt.
+// Other errors are returned immediately.
+//
+// It's safe to call Do on nil RetryPolicy,
+// which is the same as calling Do on a zero value RetryPolicy.
+func (p *RetryPolicy) Do(
+	ctx context.Context,
+	attempt func(ctx context.Context, n int) error,
+) error {
+	var policy RetryPolicy
+	if p != nil {
+		policy = *p
+	}
+
+	backoff := policy.Backoff
+	for n := 0; ; n++ {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		last := n+1 >= policy.Attempts || policy.AttemptTimeout <= 0
+		attemptCtx, cancel := ctx, context.CancelFunc(func() {})
+		if !last {
+			attemptCtx, cancel = context.WithTimeout(ctx, policy.AttemptTimeout)
+		}
+		err := attempt(attemptCtx, n)
+		timedOut := attemptCtx.Err() != nil
+		cancel()
+
+		if err == nil || last || ctx.Err() != nil {
+			return err
+		}
+		if !timedOut || !errors.Is(err, context.DeadlineExceeded) {
+			return err
+		}
+
+		if backoff > 0 {
+			timer := time.NewTimer(backoff)
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return ctx.Err()
+			case <-timer.C:
+			}
+			if policy.BackoffFactor > 1 {
+				backoff = time.Duration(float64(backoff) * policy.BackoffFactor)
+			}
+			if policy.MaxBackoff > 0 && backoff > policy.MaxBackoff {
+				backoff = policy.MaxBackoff
+			}
+		}
+	}
+}
+
+func (d *device) RetryPolicy() *RetryPolicy {
+	return &d.retry
+}
@@ -0,0 +1,195 @@
+package lifxlan_test
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"go.yhsif.com/lifxlan"
+	"go.yhsif.com/lifxlan/mock"
+)
+
+func TestRetryPolicyDo(t *testing.T) {
+	const timeout = time.Millisecond * 200
+
+	timeoutAttempt := func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+
+	t.Run(
+		"Nil",
+		func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), timeout)
+			defer cancel()
+
+			var n int
+			var p *lifxlan.RetryPolicy
+			err := p.Do(ctx, func(ctx context.Context, _ int) error {
+				n++
+				return timeoutAttempt(ctx)
+			})
+			if !errors.Is(err, context.DeadlineExceeded) {
+				t.Errorf("Expected context.DeadlineExceeded, got %v", err)
+			}
+			if n != 1 {
+				t.Errorf("Expected 1 attempt, got %d", n)
+			}
+		},
+	)
+
+	t.Run(
+		"Exhausted",
+		func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), timeout)
+			defer cancel()
+
+			const attempts = 3
+			p := &lifxlan.RetryPolicy{
+				Attempts:       attempts,
+				AttemptTimeout: time.Millisecond * 10,
+				Backoff:        time.Millisecond,
+				BackoffFactor:  2,
+			}
+			var n int
+			start := time.Now()
+			err := p.Do(ctx, func(ctx context.Context, i int) error {
+				if i != n {
+					t.Errorf("Expected attempt index %d, got %d", n, i)
+				}
+				n++
+				return timeoutAttempt(ctx)
+			})
+			if !errors.Is(err, context.DeadlineExceeded) {
+				t.Errorf("Expected context.DeadlineExceeded, got %v", err)
+			}
+			if n != attempts {
+				t.Errorf("Expected %d attempts, got %d", attempts, n)
+			}
+			// The last attempt waits until ctx is cancelled.
+			if elapsed := time.Since(start); elapsed < timeout {
+				t.Errorf("Expected Do to take at least %v, took %v", timeout, elapsed)
+			}
+		},
+	)
+
+	t.Run(
+		"Success",
+		func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), timeout)
+			defer cancel()
+
+			p := &lifxlan.RetryPolicy{
+				Attempts:       5,
+				AttemptTimeout: time.Millisecond * 10,
+			}
+			var n int
+			err := p.Do(ctx, func(ctx context.Context, i int) error {
+				n++
+				if i < 2 {
+					return timeoutAttempt(ctx)
+				}
+				return nil
+			})
+			if err != nil {
+				t.Errorf("Expected nil error, got %v", err)
+			}
+			if n != 3 {
+				t.Errorf("Expected 3 attempts, got %d", n)
+			}
+		},
+	)
+
+	t.Run(
+		"NonRetriable",
+		func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), timeout)
+			defer cancel()
+
+			p := &lifxlan.RetryPolicy{
+				Attempts:       5,
+				AttemptTimeout: time.Millisecond * 10,
+			}
+			expected := errors.New("foo")
+			var n int
+			err := p.Do(ctx, func(ctx context.Context, _ int) error {
+				n++
+				return expected
+			})
+			if err != expected {
+				t.Errorf("Expected %v, got %v", expected, err)
+			}
+			if n != 1 {
+				t.Errorf("Expected 1 attempt, got %d", n)
+			}
+		},
+	)
+}
+
+func TestRetry(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping test in short mode.")
+	}
+
+	const timeout = time.Millisecond * 200
+
+	service, device := mock.StartService(t)
+	*device.RetryPolicy() = lifxlan.RetryPolicy{
+		Attempts:       3,
+		AttemptTimeout: timeout / 5,
+	}
+
+	t.Run(
+		"GetPower",
+		func(t *testing.T) {
+			const expected = lifxlan.PowerOn
+			service.RawStatePowerPayload = &lifxlan.RawStatePowerPayload{
+				Level: expected,
+			}
+			var n int
+			service.Handlers[lifxlan.GetPower] = func(
+				s *mock.Service,
+				conn net.PacketConn,
+				addr net.Addr,
+				orig *lifxlan.Response,
+			) {
+				n++
+				if n == 1 {
+					// Drop the first one.
+					return
+				}
+				mock.DefaultHandlerFunc(s, conn, addr, orig)
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), timeout)
+			defer cancel()
+
+			power, err := device.GetPower(ctx, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if power != expected {
+				t.Errorf("Power expected %v, got %v", expected, power)
+			}
+			if n != 2 {
+				t.Errorf("Expected 2 requests, got %d", n)
+			}
+		},
+	)
+
+	t.Run(
+		"SetPower",
+		func(t *testing.T) {
+			service.AcksToDrop = 1
+
+			ctx, cancel := context.WithTimeout(context.Background(), timeout)
+			defer cancel()
+
+			if err := device.SetPower(ctx, nil, lifxlan.PowerOn, true); err != nil {
+				t.Error(err)
+			}
+		},
+	)
+}
@@ -1,45 +1,94 @@
 package lifxlan
 
 import (
-	"bytes"
 	"context"
-	"encoding/binary"
 	"fmt"
 	"net"
 )
 
+// SendOption is an option for Device.Send.
+type SendOption func(opts *sendOptions)
+
+type sendOptions struct {
+	expectReply bool
+}
+
+// ExpectReply marks the message as expecting replies even without
+// FlagAckRequired or FlagResRequired,
+// e.g. Get messages, which always cause responses.
+//
+// Send acquires the sequences (see AcquireSequence) of such messages.
+func ExpectReply() SendOption {
+	return func(opts *sendOptions) {
+		opts.expectReply = true
+	}
+}
+
+// expectsReply returns whether a message sent with flags and options expects
+// replies, in which case Send acquires its sequence.
+func expectsReply(flags AckResFlag, options ...SendOption) bool {
+	if flags&(FlagAckRequired|FlagResRequired) != 0 {
+		return true
+	}
+	var opts sendOptions
+	for _, option := range options {
+		option(&opts)
+	}
+	return opts.expectReply
+}
+
 func (d *device) Send(
 	ctx context.Context,
 	conn net.Conn,
 	flags AckResFlag,
 	message MessageType,
 	payload interface{},
+	options ...SendOption,
 ) (seq uint8, err error) {
 	if ctx.Err() != nil {
 		err = ctx.Err()
 		return
 	}
 
-	var msg []byte
-	seq = d.NextSequence()
-	buf := new(bytes.Buffer)
-	if payload != nil {
-		if err = binary.Write(buf, binary.LittleEndian, payload); err != nil {
+	if expectsReply(flags, options...) {
+		seq, err = d.AcquireSequence(ctx)
+		if err != nil {
 			return
 		}
+		defer func() {
+			if err != nil {
+				d.ReleaseSequence(seq)
+			}
+		}()
+	} else {
+		seq = d.NextSequence()
 	}
-	msg, err = GenerateMessage(
-		NotTagged,
-		d.Source(),
-		d.Target(),
-		flags,
-		seq,
-		message,
-		buf.Bytes(),
-	)
+	bufp := bufPool.Get().(*[]byte)
+	defer bufPool.Put(bufp)
+	// Leave room for the header, which needs the size of the payload.
+	msg, err := AppendPayload((*bufp)[:HeaderLength], payload)
 	if err != nil {
 		return
 	}
+	header := RawHeader{
+		Size:     uint16(len(msg)),
+		Tagged:   NotTagged,
+		Source:   d.Source(),
+		Target:   d.Target(),
+		Flags:    flags,
+		Sequence: seq,
+		Type:     message,
+	}
+	// Overwrite the header in place.
+	if _, err = header.AppendBinary(msg[:0]); err != nil {
+		return
+	}
+	// AppendPayload could have reallocated for large payloads.
+	*bufp = msg[:cap(msg)]
+
+	if err = d.RateLimiter().Wait(ctx); err != nil {
+		return
+	}
 
 	if ctx.Err() != nil {
 		err = ctx.Err()
@@ -53,12 +102,14 @@ func (d *device) Send(
 	}
 	if n < len(msg) {
 		err = fmt.Errorf(
-			"lifxlan.Device.Send: only wrote %d out of %d bytes",
+			"lifxlan.Device.Send: only wrote %d out of %d bytes: %w",
 			n,
 			len(msg),
+			ErrShortWrite,
 		)
 		return
 	}
+	tracerFor(ctx, d).OnSend(d.Target(), header, msg[HeaderLength:])
 
 	if ctx.Err() != nil {
 		err = ctx.Err()
@@ -0,0 +1,118 @@
+package lifxlan
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// DefaultSequenceLease is the duration a sequence stays in flight when it's
+// acquired with a context without deadline and never released.
+const DefaultSequenceLease = time.Second * 10
+
+// sequenceTracker tracks the in-flight sequences of a device.
+//
+// The zero value is ready to use.
+type sequenceTracker struct {
+	lock sync.Mutex
+	// The last sequence returned.
+	last uint8
+	// The expiry time of the in-flight sequences,
+	// zero value means not in flight.
+	inflight [256]time.Time
+	// Closed and replaced when any sequence is released.
+	released chan struct{}
+}
+
+// findLocked finds the next sequence not in flight after t.last.
+//
+// If all sequences are in flight, ok is false and earliest is the earliest
+// expiry time among them.
+//
+// t.lock must be held.
+func (t *sequenceTracker) findLocked(now time.Time) (seq uint8, ok bool, earliest time.Time) {
+	for i := 1; i <= len(t.inflight); i++ {
+		seq = t.last + uint8(i)
+		expiry := t.inflight[seq]
+		if expiry.IsZero() || !now.Before(expiry) {
+			t.inflight[seq] = time.Time{}
+			t.last = seq
+			return seq, true, time.Time{}
+		}
+		if earliest.IsZero() || expiry.Before(earliest) {
+			earliest = expiry
+		}
+	}
+	return 0, false, earliest
+}
+
+// next returns the next sequence not in flight without acquiring it.
+//
+// If all sequences are in flight, it returns the next one anyway.
+func (t *sequenceTracker) next() uint8 {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	if seq, ok, _ := t.findLocked(time.Now()); ok {
+		return seq
+	}
+	t.last++
+	return t.last
+}
+
+// acquire returns the next sequence not in flight,
+// and marks it in flight until expiry or release.
+//
+// It blocks until a sequence is available or ctx is done.
+func (t *sequenceTracker) acquire(ctx context.Context, expiry time.Time) (uint8, error) {
+	for {
+		t.lock.Lock()
+		seq, ok, earliest := t.findLocked(time.Now())
+		if ok {
+			t.inflight[seq] = expiry
+			t.lock.Unlock()
+			return seq, nil
+		}
+		if t.released == nil {
+			t.released = make(chan struct{})
+		}
+		released := t.released
+		t.lock.Unlock()
+
+		timer := time.NewTimer(time.Until(earliest))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return 0, ctx.Err()
+		case <-released:
+		case <-timer.C:
+		}
+		timer.Stop()
+	}
+}
+
+// release marks seq as not in flight.
+func (t *sequenceTracker) release(seq uint8) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	t.inflight[seq] = time.Time{}
+	if t.released != nil {
+		close(t.released)
+		t.released = nil
+	}
+}
+
+func (d *device) NextSequence() uint8 {
+	return d.sequences.next()
+}
+
+func (d *device) AcquireSequence(ctx context.Context) (uint8, error) {
+	expiry, ok := ctx.Deadline()
+	if !ok {
+		expiry = time.Now().Add(DefaultSequenceLease)
+	}
+	return d.sequences.acquire(ctx, expiry)
+}
+
+func (d *device) ReleaseSequence(seq uint8) {
+	d.sequences.release(seq)
+}
@@ -0,0 +1,239 @@
+package lifxlan_test
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"go.yhsif.com/lifxlan"
+	"go.yhsif.com/lifxlan/mock"
+)
+
+func TestSequence(t *testing.T) {
+	const timeout = time.Millisecond * 200
+
+	acquireAll := func(t *testing.T, ctx context.Context, d lifxlan.Device) map[uint8]bool {
+		t.Helper()
+		seqs := make(map[uint8]bool)
+		for i := 0; i < 256; i++ {
+			seq, err := d.AcquireSequence(ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if seqs[seq] {
+				t.Fatalf("Sequence %d acquired twice", seq)
+			}
+			seqs[seq] = true
+		}
+		return seqs
+	}
+
+	t.Run(
+		"Exhausted",
+		func(t *testing.T) {
+			d := lifxlan.NewDevice("", lifxlan.ServiceUDP, lifxlan.Target(1))
+			acquireAll(t, context.Background(), d)
+
+			ctx, cancel := context.WithTimeout(context.Background(), timeout/4)
+			defer cancel()
+			if _, err := d.AcquireSequence(ctx); !errors.Is(err, context.DeadlineExceeded) {
+				t.Errorf("Expected %v, got %v", context.DeadlineExceeded, err)
+			}
+
+			const released = 42
+			go func() {
+				time.Sleep(timeout / 4)
+				d.ReleaseSequence(released)
+			}()
+			ctx, cancel = context.WithTimeout(context.Background(), timeout)
+			defer cancel()
+			seq, err := d.AcquireSequence(ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if seq != released {
+				t.Errorf("Expected released sequence %d, got %d", released, seq)
+			}
+		},
+	)
+
+	t.Run(
+		"Expired",
+		func(t *testing.T) {
+			d := lifxlan.NewDevice("", lifxlan.ServiceUDP, lifxlan.Target(1))
+			func() {
+				ctx, cancel := context.WithTimeout(context.Background(), timeout/4)
+				defer cancel()
+				acquireAll(t, ctx, d)
+			}()
+
+			ctx, cancel := context.WithTimeout(context.Background(), timeout)
+			defer cancel()
+			if _, err := d.AcquireSequence(ctx); err != nil {
+				t.Errorf("Expected sequences to expire, got %v", err)
+			}
+		},
+	)
+
+	t.Run(
+		"NextSequence",
+		func(t *testing.T) {
+			d := lifxlan.NewDevice("", lifxlan.ServiceUDP, lifxlan.Target(1))
+			inflight, err := d.AcquireSequence(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+			for i := 0; i < 512; i++ {
+				if seq := d.NextSequence(); seq == inflight {
+					t.Fatalf("NextSequence returned in-flight sequence %d", seq)
+				}
+			}
+
+			d.ReleaseSequence(inflight)
+			seen := false
+			for i := 0; i < 256; i++ {
+				if d.NextSequence() == inflight {
+					seen = true
+				}
+			}
+			if !seen {
+				t.Errorf("NextSequence never returned released sequence %d", inflight)
+			}
+		},
+	)
+
+	t.Run(
+		"Send",
+		func(t *testing.T) {
+			d := lifxlan.NewDevice("", lifxlan.ServiceUDP, lifxlan.Target(1))
+			ctx := context.Background()
+			for i := 0; i < 256; i++ {
+				if _, err := d.Send(
+					ctx,
+					benchConn{},
+					lifxlan.FlagAckRequired,
+					lifxlan.SetPower,
+					nil, // payload
+				); err != nil {
+					t.Fatal(err)
+				}
+			}
+			// Messages not expecting replies don't need in-flight sequences.
+			if _, err := d.Send(
+				ctx,
+				benchConn{},
+				0, // flags
+				lifxlan.SetPower,
+				nil, // payload
+			); err != nil {
+				t.Fatal(err)
+			}
+
+			for _, c := range []struct {
+				label   string
+				flags   lifxlan.AckResFlag
+				options []lifxlan.SendOption
+			}{
+				{
+					label: "ResRequired",
+					flags: lifxlan.FlagResRequired,
+				},
+				{
+					label:   "ExpectReply",
+					flags:   0,
+					options: []lifxlan.SendOption{lifxlan.ExpectReply()},
+				},
+			} {
+				t.Run(c.label, func(t *testing.T) {
+					ctx, cancel := context.WithTimeout(context.Background(), timeout/4)
+					defer cancel()
+					if _, err := d.Send(
+						ctx,
+						benchConn{},
+						c.flags,
+						lifxlan.GetPower,
+						nil, // payload
+						c.options...,
+					); !errors.Is(err, context.DeadlineExceeded) {
+						t.Errorf("Expected %v, got %v", context.DeadlineExceeded, err)
+					}
+				})
+			}
+		},
+	)
+
+	t.Run(
+		"Request",
+		func(t *testing.T) {
+			if testing.Short() {
+				t.Skip("skipping test in short mode.")
+			}
+
+			// A device never replying.
+			conn, err := net.ListenPacket("udp", mock.ListenAddr)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer conn.Close()
+			d := lifxlan.NewDevice(
+				conn.LocalAddr().String(),
+				lifxlan.ServiceUDP,
+				lifxlan.Target(1),
+			)
+
+			tracer := new(sequenceTracer)
+			ctx, cancel := context.WithCancel(
+				lifxlan.WithTracer(context.Background(), tracer),
+			)
+			defer cancel()
+			const n = 300
+			var wg sync.WaitGroup
+			errs := make([]error, n)
+			for i := 0; i < n; i++ {
+				wg.Add(1)
+				go func(i int) {
+					defer wg.Done()
+					_, errs[i] = d.GetPower(ctx, nil)
+				}(i)
+			}
+			time.Sleep(timeout)
+			cancel()
+			wg.Wait()
+
+			for i, err := range errs {
+				if !errors.Is(err, context.Canceled) {
+					t.Errorf("Request #%d expected %v, got %v", i, context.Canceled, err)
+				}
+			}
+			tracer.lock.Lock()
+			defer tracer.lock.Unlock()
+			if len(tracer.seqs) != 256 {
+				t.Errorf("Expected 256 messages sent, got %d", len(tracer.seqs))
+			}
+			seen := make(map[uint8]bool)
+			for _, seq := range tracer.seqs {
+				if seen[seq] {
+					t.Errorf("Sequence %d sent twice while in flight", seq)
+				}
+				seen[seq] = true
+			}
+		},
+	)
+}
+
+// sequenceTracer records the sequences of the sent messages.
+type sequenceTracer struct {
+	lifxlan.NopTracer
+
+	lock sync.Mutex
+	seqs []uint8
+}
+
+func (t *sequenceTracer) OnSend(_ lifxlan.Target, header lifxlan.RawHeader, _ []byte) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	t.seqs = append(t.seqs, header.Sequence)
+}
@@ -0,0 +1,292 @@
+package lifxlan
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// LatencyBuckets are the upper bounds of the buckets used by
+// LatencyHistogram.
+//
+// The last bucket of LatencyHistogram.Counts has no upper bound.
+var LatencyBuckets = [...]time.Duration{
+	time.Millisecond * 5,
+	time.Millisecond * 10,
+	time.Millisecond * 20,
+	time.Millisecond * 50,
+	time.Millisecond * 100,
+	time.Millisecond * 200,
+	time.Millisecond * 500,
+	time.Second,
+	time.Second * 2,
+}
+
+// LatencyHistogram is the histogram of round-trip latencies.
+type LatencyHistogram struct {
+	// Counts[i] is the number of latencies <= LatencyBuckets[i]
+	// (and > LatencyBuckets[i-1]).
+	// The last one counts the latencies > all LatencyBuckets.
+	Counts [len(LatencyBuckets) + 1]uint64
+
+	// The sum, min, and max of all the latencies.
+	Sum time.Duration
+	Min time.Duration
+	Max time.Duration
+}
+
+func (h *LatencyHistogram) add(latency time.Duration) {
+	i := 0
+	for i < len(LatencyBuckets) && latency > LatencyBuckets[i] {
+		i++
+	}
+	if h.Count() == 0 || latency < h.Min {
+		h.Min = latency
+	}
+	if latency > h.Max {
+		h.Max = latency
+	}
+	h.Counts[i]++
+	h.Sum += latency
+}
+
+// Count returns the total number of latencies in the histogram.
+func (h LatencyHistogram) Count() uint64 {
+	var n uint64
+	for _, c := range h.Counts {
+		n += c
+	}
+	return n
+}
+
+// Mean returns the mean latency, or 0 if the histogram is empty.
+func (h LatencyHistogram) Mean() time.Duration {
+	n := h.Count()
+	if n == 0 {
+		return 0
+	}
+	return h.Sum / time.Duration(n)
+}
+
+// Quantile returns the upper bound of the bucket containing the q-quantile
+// (0 <= q <= 1) of the latencies, e.g. Quantile(0.99) for p99.
+//
+// If the q-quantile falls in the last bucket, Max is returned.
+// If the histogram is empty, it returns 0.
+func (h LatencyHistogram) Quantile(q float64) time.Duration {
+	n := h.Count()
+	if n == 0 {
+		return 0
+	}
+	rank := uint64(math.Ceil(q * float64(n)))
+	if rank < 1 {
+		rank = 1
+	}
+	var seen uint64
+	for i, c := range h.Counts[:len(LatencyBuckets)] {
+		seen += c
+		if seen >= rank {
+			return LatencyBuckets[i]
+		}
+	}
+	return h.Max
+}
+
+// LossStats is the number of messages expecting replies (either responses or
+// acks), and what happened to them.
+type LossStats struct {
+	// Messages sent.
+	Sent uint64
+	// Messages got the reply.
+	Received uint64
+	// Messages didn't get the reply before the API call returned.
+	Lost uint64
+}
+
+// Rate returns the loss rate of the finished messages,
+// or 0 if there's none.
+func (s LossStats) Rate() float64 {
+	finished := s.Received + s.Lost
+	if finished == 0 {
+		return 0
+	}
+	return float64(s.Lost) / float64(finished)
+}
+
+// Stats is a snapshot of the link quality statistics of a device.
+type Stats struct {
+	// Messages expecting responses, e.g. GetPower.
+	Requests LossStats
+	// Messages expecting acks, e.g. SetPower with ack.
+	Acks LossStats
+
+	// The round-trip latencies of both responses and acks.
+	Latency LatencyHistogram
+
+	// The last time a reply was received from the device,
+	// zero value means never.
+	LastSeen time.Time
+
+	// The number of messages lost since the last reply received,
+	// of both responses and acks.
+	//
+	// The lost retransmissions of API calls got replies are not counted,
+	// as the device is still reachable.
+	ConsecutiveLost uint64
+}
+
+// LinkStats collects the link quality statistics of a device.
+//
+// Device APIs record to it automatically.
+// Device API implementations in other packages can use the Record* functions
+// to record their own messages.
+//
+// It's safe for concurrent use.
+// The zero value is ready to use.
+type LinkStats struct {
+	lock  sync.Mutex
+	stats Stats
+
+	// If non-nil, it's called with Stats.ConsecutiveLost after every lost
+	// message, outside of the lock.
+	onLost func(consecutive uint64)
+}
+
+func (s *LinkStats) lossStats(ack bool) *LossStats {
+	if ack {
+		return &s.stats.Acks
+	}
+	return &s.stats.Requests
+}
+
+// RecordSent records that a message expecting a reply was sent.
+//
+// ack should be true if the reply is an ack, false if it's a response.
+func (s *LinkStats) RecordSent(ack bool) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.lossStats(ack).Sent++
+}
+
+// RecordReceived records that the reply to a message was received,
+// with the round-trip latency.
+func (s *LinkStats) RecordReceived(ack bool, latency time.Duration) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.lossStats(ack).Received++
+	s.stats.Latency.add(latency)
+	s.stats.LastSeen = time.Now()
+	s.stats.ConsecutiveLost = 0
+}
+
+// RecordLost records that the reply to a message was not received.
+func (s *LinkStats) RecordLost(ack bool) {
+	s.recordLost(ack, true)
+}
+
+// recordLost records that the reply to a message was not received,
+// and only counts it into ConsecutiveLost when countConsecutive is true.
+func (s *LinkStats) recordLost(ack bool, countConsecutive bool) {
+	s.lock.Lock()
+	s.lossStats(ack).Lost++
+	if !countConsecutive {
+		s.lock.Unlock()
+		return
+	}
+	s.stats.ConsecutiveLost++
+	consecutive := s.stats.ConsecutiveLost
+	s.lock.Unlock()
+
+	if s.onLost != nil {
+		s.onLost(consecutive)
+	}
+}
+
+// Snapshot returns a snapshot of the collected statistics.
+func (s *LinkStats) Snapshot() Stats {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.stats
+}
+
+// Reset clears all the collected statistics.
+func (s *LinkStats) Reset() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.stats = Stats{}
+}
+
+// replyTracker tracks the replies to messages sent in a single API call,
+// records them to LinkStats,
+// and releases their acquired sequences when the API call finishes.
+type replyTracker struct {
+	d        Device
+	stats    *LinkStats
+	ack      bool
+	sentAt   map[uint8]time.Time
+	done     map[uint8]bool
+	acquired map[uint8]bool
+}
+
+func newReplyTracker(d Device, ack bool) *replyTracker {
+	return &replyTracker{
+		d:        d,
+		stats:    d.LinkStats(),
+		ack:      ack,
+		sentAt:   make(map[uint8]time.Time),
+		done:     make(map[uint8]bool),
+		acquired: make(map[uint8]bool),
+	}
+}
+
+// sent records a sent message.
+//
+// acquired tells whether seq was acquired by Send (see AcquireSequence),
+// only those are released by finish.
+func (t *replyTracker) sent(seq uint8, acquired bool) {
+	t.sentAt[seq] = time.Now()
+	if acquired {
+		t.acquired[seq] = true
+	}
+	t.stats.RecordSent(t.ack)
+}
+
+// received returns false if seq was not sent by this tracker.
+func (t *replyTracker) received(seq uint8) bool {
+	at, ok := t.sentAt[seq]
+	if !ok {
+		return false
+	}
+	if !t.done[seq] {
+		t.done[seq] = true
+		t.stats.RecordReceived(t.ack, time.Since(at))
+	}
+	return true
+}
+
+// finish records all the sent messages without replies as lost,
+// and releases all the acquired sequences.
+//
+// If any reply was received,
+// the lost ones (e.g. retransmissions) don't count into ConsecutiveLost.
+func (t *replyTracker) finish() {
+	replied := len(t.done) > 0
+	for seq := range t.sentAt {
+		if !t.done[seq] {
+			t.done[seq] = true
+			t.stats.recordLost(t.ack, !replied)
+		}
+		if t.acquired[seq] {
+			delete(t.acquired, seq)
+			t.d.ReleaseSequence(seq)
+		}
+	}
+}
+
+func (d *device) LinkStats() *LinkStats {
+	return &d.stats
+}
+
+func (d *device) Stats() Stats {
+	return d.stats.Snapshot()
+}
@@ -0,0 +1,197 @@
+package lifxlan_test
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"go.yhsif.com/lifxlan"
+	"go.yhsif.com/lifxlan/mock"
+)
+
+func TestLinkStats(t *testing.T) {
+	var stats lifxlan.LinkStats
+	latencies := []time.Duration{
+		time.Millisecond * 3,
+		time.Millisecond * 4,
+		time.Millisecond * 15,
+		time.Second * 3,
+	}
+	for _, latency := range latencies {
+		stats.RecordSent(false)
+		stats.RecordReceived(false, latency)
+	}
+	stats.RecordSent(false)
+	stats.RecordLost(false)
+	stats.RecordSent(true)
+
+	s := stats.Snapshot()
+	expectedRequests := lifxlan.LossStats{
+		Sent:     5,
+		Received: 4,
+		Lost:     1,
+	}
+	if s.Requests != expectedRequests {
+		t.Errorf("Requests expected %+v, got %+v", expectedRequests, s.Requests)
+	}
+	if rate := s.Requests.Rate(); rate != 0.2 {
+		t.Errorf("Requests.Rate() expected 0.2, got %v", rate)
+	}
+	expectedAcks := lifxlan.LossStats{
+		Sent: 1,
+	}
+	if s.Acks != expectedAcks {
+		t.Errorf("Acks expected %+v, got %+v", expectedAcks, s.Acks)
+	}
+	if rate := s.Acks.Rate(); rate != 0 {
+		t.Errorf("Acks.Rate() expected 0, got %v", rate)
+	}
+	if s.LastSeen.IsZero() {
+		t.Error("LastSeen expected non-zero")
+	}
+
+	h := s.Latency
+	if n := h.Count(); n != 4 {
+		t.Errorf("Latency.Count() expected 4, got %d", n)
+	}
+	if h.Counts[0] != 2 {
+		t.Errorf("Latency.Counts[0] expected 2, got %d", h.Counts[0])
+	}
+	if h.Min != latencies[0] {
+		t.Errorf("Latency.Min expected %v, got %v", latencies[0], h.Min)
+	}
+	if h.Max != latencies[3] {
+		t.Errorf("Latency.Max expected %v, got %v", latencies[3], h.Max)
+	}
+	for q, expected := range map[float64]time.Duration{
+		0:    time.Millisecond * 5,
+		0.5:  time.Millisecond * 5,
+		0.75: time.Millisecond * 20,
+		1:    latencies[3],
+	} {
+		if got := h.Quantile(q); got != expected {
+			t.Errorf("Latency.Quantile(%v) expected %v, got %v", q, expected, got)
+		}
+	}
+
+	stats.Reset()
+	if s := stats.Snapshot(); s.Latency.Count() != 0 || !s.LastSeen.IsZero() {
+		t.Errorf("Expected empty stats after Reset, got %+v", s)
+	}
+}
+
+func TestDeviceStats(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping test in short mode.")
+	}
+
+	const timeout = time.Millisecond * 200
+
+	service, device := mock.StartService(t)
+	*device.RetryPolicy() = lifxlan.RetryPolicy{
+		Attempts:       3,
+		AttemptTimeout: timeout / 5,
+	}
+
+	t.Run(
+		"Echo",
+		func(t *testing.T) {
+			device.LinkStats().Reset()
+
+			ctx, cancel := context.WithTimeout(context.Background(), timeout)
+			defer cancel()
+
+			if err := device.Echo(ctx, nil, nil); err != nil {
+				t.Fatal(err)
+			}
+			s := device.Stats()
+			expected := lifxlan.LossStats{
+				Sent:     1,
+				Received: 1,
+			}
+			if s.Requests != expected {
+				t.Errorf("Requests expected %+v, got %+v", expected, s.Requests)
+			}
+			if s.Latency.Count() != 1 {
+				t.Errorf("Latency.Count() expected 1, got %d", s.Latency.Count())
+			}
+			if s.LastSeen.IsZero() {
+				t.Error("LastSeen expected non-zero")
+			}
+		},
+	)
+
+	t.Run(
+		"AckLost",
+		func(t *testing.T) {
+			device.LinkStats().Reset()
+			service.AcksToDrop = 1
+
+			ctx, cancel := context.WithTimeout(context.Background(), timeout)
+			defer cancel()
+
+			if err := device.SetPower(ctx, nil, lifxlan.PowerOn, true); err != nil {
+				t.Fatal(err)
+			}
+			s := device.Stats()
+			expected := lifxlan.LossStats{
+				Sent:     2,
+				Received: 1,
+				Lost:     1,
+			}
+			if s.Acks != expected {
+				t.Errorf("Acks expected %+v, got %+v", expected, s.Acks)
+			}
+			if rate := s.Acks.Rate(); rate != 0.5 {
+				t.Errorf("Acks.Rate() expected 0.5, got %v", rate)
+			}
+			if s.ConsecutiveLost != 0 {
+				t.Errorf("ConsecutiveLost expected 0, got %d", s.ConsecutiveLost)
+			}
+		},
+	)
+
+	t.Run(
+		"ResponseLost",
+		func(t *testing.T) {
+			device.LinkStats().Reset()
+			var n int
+			service.Handlers[lifxlan.GetPower] = func(
+				s *mock.Service,
+				conn net.PacketConn,
+				addr net.Addr,
+				orig *lifxlan.Response,
+			) {
+				// Drop the first one.
+				n++
+				if n > 1 {
+					mock.DefaultHandlerFunc(s, conn, addr, orig)
+				}
+			}
+			defer delete(service.Handlers, lifxlan.GetPower)
+			service.RawStatePowerPayload = &lifxlan.RawStatePowerPayload{
+				Level: lifxlan.PowerOn,
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), timeout)
+			defer cancel()
+
+			if _, err := device.GetPower(ctx, nil); err != nil {
+				t.Fatal(err)
+			}
+			s := device.Stats()
+			expected := lifxlan.LossStats{
+				Sent:     2,
+				Received: 1,
+				Lost:     1,
+			}
+			if s.Requests != expected {
+				t.Errorf("Requests expected %+v, got %+v", expected, s.Requests)
+			}
+			if s.ConsecutiveLost != 0 {
+				t.Errorf("ConsecutiveLost expected 0, got %d", s.ConsecutiveLost)
+			}
+		},
+	)
+}
@@ -1,11 +1,10 @@
 package tile
 
 import (
-	"bytes"
 	"context"
 	"encoding/binary"
+	"io"
 	"net"
-	"sync"
 	"time"
 
 	"go.yhsif.com/lifxlan"
@@ -54,6 +53,53 @@ type RawSetTileState64Payload struct {
 	Colors    [64]lifxlan.Color
 }
 
+// RawSetTileState64PayloadLength is the length of the encoded
+// RawSetTileState64Payload.
+const RawSetTileState64PayloadLength = 10 + 64*lifxlan.ColorLength
+
+// MarshalBinary implements encoding.BinaryMarshaler.
+func (p *RawSetTileState64Payload) MarshalBinary() ([]byte, error) {
+	return p.AppendBinary(make([]byte, 0, RawSetTileState64PayloadLength))
+}
+
+// AppendBinary implements lifxlan.BinaryAppender.
+func (p *RawSetTileState64Payload) AppendBinary(b []byte) ([]byte, error) {
+	var buf [10]byte
+	buf[0] = p.TileIndex
+	buf[1] = p.Length
+	buf[3] = p.X
+	buf[4] = p.Y
+	buf[5] = p.Width
+	binary.LittleEndian.PutUint32(buf[6:], uint32(p.Duration))
+	b = append(b, buf[:]...)
+	for i := range p.Colors {
+		var err error
+		if b, err = p.Colors[i].AppendBinary(b); err != nil {
+			return b, err
+		}
+	}
+	return b, nil
+}
+
+// UnmarshalBinary implements encoding.BinaryUnmarshaler.
+func (p *RawSetTileState64Payload) UnmarshalBinary(data []byte) error {
+	if len(data) < RawSetTileState64PayloadLength {
+		return io.ErrUnexpectedEOF
+	}
+	p.TileIndex = data[0]
+	p.Length = data[1]
+	p.X = data[3]
+	p.Y = data[4]
+	p.Width = data[5]
+	p.Duration = lifxlan.TransitionTime(binary.LittleEndian.Uint32(data[6:]))
+	for i := range p.Colors {
+		if err := p.Colors[i].UnmarshalBinary(data[10+i*lifxlan.ColorLength:]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func (td *device) SetColors(
 	ctx context.Context,
 	conn net.Conn,
@@ -61,23 +107,51 @@ func (td *device) SetColors(
 	transition time.Duration,
 	ack bool,
 ) error {
+	payloads := td.makePayloads(cb, transition)
+	messages := make([]interface{}, len(payloads))
+	for i, payload := range payloads {
+		messages[i] = payload
+	}
+	return lifxlan.Commands(ctx, td, conn, SetTileState64, messages, ack)
+}
+
+func (td *device) SetColorsAndGet(
+	ctx context.Context,
+	conn net.Conn,
+	cb ColorBoard,
+	transition time.Duration,
+) (ColorBoard, error) {
 	if ctx.Err() != nil {
-		return ctx.Err()
+		return nil, ctx.Err()
 	}
 
 	if conn == nil {
 		newConn, err := td.Dial()
 		if err != nil {
-			return err
+			return nil, err
 		}
 		defer newConn.Close()
 		conn = newConn
 
 		if ctx.Err() != nil {
-			return ctx.Err()
+			return nil, ctx.Err()
 		}
 	}
 
+	// SetTileState64 never causes responses even with res_required,
+	// so wait for the acks before getting the colors.
+	if err := td.SetColors(ctx, conn, cb, transition, true); err != nil {
+		return nil, err
+	}
+	return td.GetColors(ctx, conn)
+}
+
+// makePayloads generates the SetTileState64 payloads for all the tiles from
+// the color board.
+func (td *device) makePayloads(
+	cb ColorBoard,
+	transition time.Duration,
+) []*RawSetTileState64Payload {
 	payloads := make([]*RawSetTileState64Payload, len(td.tiles))
 	sanitizedBlack := td.SanitizeColor(lifxlan.ColorBlack)
 	for i := range payloads {
@@ -106,61 +180,7 @@ func (td *device) SetColors(
 			}
 		}
 	}
-
-	var flags lifxlan.AckResFlag
-	if ack {
-		flags |= lifxlan.FlagAckRequired
-	}
-
-	var wg sync.WaitGroup
-	wg.Add(len(payloads))
-	errChan := make(chan error, len(payloads))
-	sentChan := make(chan uint8, len(payloads))
-	for _, payload := range payloads {
-		go func(payload *RawSetTileState64Payload) {
-			defer wg.Done()
-			seq, err := td.Send(
-				ctx,
-				conn,
-				flags,
-				SetTileState64,
-				payload,
-			)
-			if err != nil {
-				errChan <- err
-				return
-			}
-			sentChan <- seq
-		}(payload)
-	}
-	wg.Wait()
-
-	seqs := make([]uint8, 0)
-	if err := func() error {
-		var n int
-		for {
-			select {
-			case <-ctx.Done():
-				return ctx.Err()
-			case err := <-errChan:
-				return err
-			case seq := <-sentChan:
-				n++
-				seqs = append(seqs, seq)
-				if n >= len(payloads) {
-					// All API calls successfully sent.
-					return nil
-				}
-			}
-		}
-	}(); err != nil {
-		return err
-	}
-
-	if ack {
-		return lifxlan.WaitForAcks(ctx, conn, td.Source(), seqs...)
-	}
-	return nil
+	return payloads
 }
 
 // RawGetTileState64Payload defines the struct to be used for encoding and
@@ -176,6 +196,29 @@ type RawGetTileState64Payload struct {
 	Width     uint8
 }
 
+// MarshalBinary implements encoding.BinaryMarshaler.
+func (p *RawGetTileState64Payload) MarshalBinary() ([]byte, error) {
+	return p.AppendBinary(make([]byte, 0, 6))
+}
+
+// AppendBinary implements lifxlan.BinaryAppender.
+func (p *RawGetTileState64Payload) AppendBinary(b []byte) ([]byte, error) {
+	return append(b, p.TileIndex, p.Length, 0, p.X, p.Y, p.Width), nil
+}
+
+// UnmarshalBinary implements encoding.BinaryUnmarshaler.
+func (p *RawGetTileState64Payload) UnmarshalBinary(data []byte) error {
+	if len(data) < 6 {
+		return io.ErrUnexpectedEOF
+	}
+	p.TileIndex = data[0]
+	p.Length = data[1]
+	p.X = data[3]
+	p.Y = data[4]
+	p.Width = data[5]
+	return nil
+}
+
 // RawStateTileState64Payload defines the struct to be used for encoding and
 // decoding.
 //
@@ -189,83 +232,106 @@ type RawStateTileState64Payload struct {
 	Colors    [64]lifxlan.Color
 }
 
-func (td *device) GetColors(
-	ctx context.Context,
-	conn net.Conn,
-) (ColorBoard, error) {
-	if ctx.Err() != nil {
-		return nil, ctx.Err()
-	}
+// RawStateTileState64PayloadLength is the length of the encoded
+// RawStateTileState64Payload.
+const RawStateTileState64PayloadLength = 5 + 64*lifxlan.ColorLength
 
-	if conn == nil {
-		newConn, err := td.Dial()
-		if err != nil {
-			return nil, err
+// MarshalBinary implements encoding.BinaryMarshaler.
+func (p *RawStateTileState64Payload) MarshalBinary() ([]byte, error) {
+	return p.AppendBinary(make([]byte, 0, RawStateTileState64PayloadLength))
+}
+
+// AppendBinary implements lifxlan.BinaryAppender.
+func (p *RawStateTileState64Payload) AppendBinary(b []byte) ([]byte, error) {
+	b = append(b, p.TileIndex, 0, p.X, p.Y, p.Width)
+	for i := range p.Colors {
+		var err error
+		if b, err = p.Colors[i].AppendBinary(b); err != nil {
+			return b, err
 		}
-		defer newConn.Close()
-		conn = newConn
+	}
+	return b, nil
+}
 
-		if ctx.Err() != nil {
-			return nil, ctx.Err()
+// UnmarshalBinary implements encoding.BinaryUnmarshaler.
+func (p *RawStateTileState64Payload) UnmarshalBinary(data []byte) error {
+	if len(data) < RawStateTileState64PayloadLength {
+		return io.ErrUnexpectedEOF
+	}
+	p.TileIndex = data[0]
+	p.X = data[2]
+	p.Y = data[3]
+	p.Width = data[4]
+	for i := range p.Colors {
+		if err := p.Colors[i].UnmarshalBinary(data[5+i*lifxlan.ColorLength:]); err != nil {
+			return err
 		}
 	}
+	return nil
+}
 
-	// Send
-	seq, err := td.Send(
+func (td *device) GetColors(
+	ctx context.Context,
+	conn net.Conn,
+) (ColorBoard, error) {
+	received := make([]int, len(td.tiles))
+	cb := MakeColorBoard(td.Width(), td.Height())
+	if err := lifxlan.RequestFunc(
 		ctx,
+		td,
 		conn,
-		0, // flags
 		GetTileState64,
 		&RawGetTileState64Payload{
 			TileIndex: td.startIndex,
 			Length:    uint8(len(td.tiles)),
 			Width:     td.TileWidth(0),
 		},
-	)
-	if err != nil {
-		return nil, err
-	}
-
-	// Read responses
-	received := make([]int, len(td.tiles))
-	cb := MakeColorBoard(td.Width(), td.Height())
-	for {
-		resp, err := lifxlan.ReadNextResponse(ctx, conn)
-		if err != nil {
-			return nil, err
-		}
-		if resp.Sequence != seq || resp.Source != td.Source() {
-			continue
-		}
-		if resp.Message != StateTileState64 {
-			continue
-		}
+		StateTileState64,
+		func(resp *lifxlan.Response) (bool, error) {
+			var raw RawStateTileState64Payload
+			if err := resp.DecodePayload(&raw); err != nil {
+				return false, err
+			}
 
-		var raw RawStateTileState64Payload
-		r := bytes.NewReader(resp.Payload)
-		if err := binary.Read(r, binary.LittleEndian, &raw); err != nil {
-			return nil, err
-		}
+			ti, ok := td.fillColorBoard(cb, &raw)
+			if !ok {
+				// Not one of our tiles.
+				return false, nil
+			}
+			received[ti] = 1
 
-		// tile index
-		ti := raw.TileIndex - td.startIndex
-		received[ti] = 1
-		tile := td.tiles[ti]
-		for x := 0; x < int(tile.Width); x++ {
-			for y := 0; y < int(tile.Height); y++ {
-				// c is the coordinate on the color board.
-				c := td.board.ReverseData[ti][x][y]
-				cb[c.X][c.Y] = &raw.Colors[x*int(tile.Width)+y]
+			n := 0
+			for _, rec := range received {
+				n += rec
 			}
-		}
+			// Done when got responses for all tiles.
+			return n >= len(td.tiles), nil
+		},
+	); err != nil {
+		return nil, err
+	}
+	return cb, nil
+}
 
-		n := 0
-		for _, rec := range received {
-			n += rec
-		}
-		if n >= len(td.tiles) {
-			// Got responses for all tiles.
-			return cb, nil
+// fillColorBoard fills the colors of the tile in raw into cb,
+// and returns the index of the tile in td.tiles.
+//
+// If raw is not for one of the tiles in td, cb is unchanged and ok is false.
+func (td *device) fillColorBoard(
+	cb ColorBoard,
+	raw *RawStateTileState64Payload,
+) (ti int, ok bool) {
+	ti = int(raw.TileIndex) - int(td.startIndex)
+	if ti < 0 || ti >= len(td.tiles) {
+		return 0, false
+	}
+	tile := td.tiles[ti]
+	for x := 0; x < int(tile.Width); x++ {
+		for y := 0; y < int(tile.Height); y++ {
+			// c is the coordinate on the color board.
+			c := td.board.ReverseData[ti][x][y]
+			cb[c.X][c.Y] = &raw.Colors[x*int(tile.Width)+y]
 		}
 	}
+	return ti, true
 }
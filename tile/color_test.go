@@ -404,6 +404,83 @@ func TestColorsAPIs(t *testing.T) {
 					}
 				},
 			)
+
+			t.Run(
+				"Retry",
+				func(t *testing.T) {
+					service.AcksToDrop = 1
+					delete(service.Handlers, tile.SetTileState64)
+
+					*td.RetryPolicy() = lifxlan.RetryPolicy{
+						Attempts:       2,
+						AttemptTimeout: timeout / 4,
+					}
+					defer func() {
+						*td.RetryPolicy() = lifxlan.RetryPolicy{}
+					}()
+
+					ctx, cancel := context.WithTimeout(context.Background(), timeout)
+					defer cancel()
+
+					if err := td.SetColors(ctx, nil, nil, 0, true); err != nil {
+						t.Error(err)
+					}
+				},
+			)
+		},
+	)
+
+	t.Run(
+		"SetColorsAndGet",
+		func(t *testing.T) {
+			service.AcksToDrop = 0
+			// Apply the colors to the states returned by GetTileState64.
+			service.RawStateTileState64Payloads = []*tile.RawStateTileState64Payload{
+				&stateColor1,
+				&stateColor2,
+			}
+			service.Handlers[tile.SetTileState64] = func(
+				s *mock.Service,
+				_ net.PacketConn,
+				_ net.Addr,
+				orig *lifxlan.Response,
+			) {
+				var raw tile.RawSetTileState64Payload
+				if err := orig.DecodePayload(&raw); err != nil {
+					t.Error(err)
+					return
+				}
+				s.RawStateTileState64Payloads[raw.TileIndex] = &tile.RawStateTileState64Payload{
+					TileIndex: raw.TileIndex,
+					X:         raw.X,
+					Y:         raw.Y,
+					Width:     raw.Width,
+					Colors:    raw.Colors,
+				}
+			}
+			defer delete(service.Handlers, tile.SetTileState64)
+
+			ctx, cancel := context.WithTimeout(context.Background(), timeout)
+			defer cancel()
+
+			cb := tile.MakeColorBoard(td.Width(), td.Height())
+			cb[0][0] = lifxlan.FromColor(color.White, 0)
+
+			ret, err := td.SetColorsAndGet(ctx, nil, cb, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for x := 0; x < 16; x++ {
+				for y := 0; y < 8; y++ {
+					expected := td.SanitizeColor(lifxlan.ColorBlack)
+					if x == 0 && y == 0 {
+						expected = td.SanitizeColor(*cb[x][y])
+					}
+					if got := ret.GetColor(x, y); got == nil || *got != expected {
+						t.Errorf("Expected color %+v at (%d, %d), got %+v", expected, x, y, got)
+					}
+				}
+			}
 		},
 	)
 }
@@ -46,6 +46,19 @@ type Device interface {
 	// the device.
 	SetColors(ctx context.Context, conn net.Conn, cb ColorBoard, transition time.Duration, ack bool) error
 
+	// SetColorsAndGet is similar to SetColors with ack,
+	// but also gets the resulting colors via GetColors after all the acks are
+	// received, and returns them as a color board.
+	//
+	// The returned colors are what the device actually applied,
+	// which could be different from cb after sanitization.
+	// With non-zero transition,
+	// they could be the colors in the middle of the transition.
+	//
+	// Tiles don't reply to SetTileState64 messages with responses even with
+	// res_required, so it takes two round trips.
+	SetColorsAndGet(ctx context.Context, conn net.Conn, cb ColorBoard, transition time.Duration) (ColorBoard, error)
+
 	// TileWidth returns the width of the i-th tile.
 	//
 	// If i is out of bound, it returns the width of the first tile (index 0)
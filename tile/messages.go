@@ -12,3 +12,11 @@ const (
 	StateTileState64 lifxlan.MessageType = 711
 	SetTileState64   lifxlan.MessageType = 715
 )
+
+func init() {
+	lifxlan.RegisterMessage(GetDeviceChain, "tile.GetDeviceChain", nil)
+	lifxlan.RegisterMessage(StateDeviceChain, "tile.StateDeviceChain", RawStateDeviceChainPayload{})
+	lifxlan.RegisterMessage(GetTileState64, "tile.GetTileState64", RawGetTileState64Payload{})
+	lifxlan.RegisterMessage(StateTileState64, "tile.StateTileState64", RawStateTileState64Payload{})
+	lifxlan.RegisterMessage(SetTileState64, "tile.SetTileState64", RawSetTileState64Payload{})
+}
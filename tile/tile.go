@@ -1,6 +1,8 @@
 package tile
 
 import (
+	"encoding/binary"
+	"io"
 	"math"
 
 	"go.yhsif.com/lifxlan"
@@ -29,6 +31,54 @@ type RawTileDevice struct {
 	_               [4]byte // reserved
 }
 
+// RawTileDeviceLength is the length of the encoded RawTileDevice.
+const RawTileDeviceLength = 55
+
+// MarshalBinary implements encoding.BinaryMarshaler.
+func (raw *RawTileDevice) MarshalBinary() ([]byte, error) {
+	return raw.AppendBinary(make([]byte, 0, RawTileDeviceLength))
+}
+
+// AppendBinary implements lifxlan.BinaryAppender.
+func (raw *RawTileDevice) AppendBinary(b []byte) ([]byte, error) {
+	var buf [19]byte
+	binary.LittleEndian.PutUint16(buf[0:], uint16(raw.AccelMeasX))
+	binary.LittleEndian.PutUint16(buf[2:], uint16(raw.AccelMeasY))
+	binary.LittleEndian.PutUint16(buf[4:], uint16(raw.AccelMeasZ))
+	binary.LittleEndian.PutUint32(buf[8:], math.Float32bits(raw.UserX))
+	binary.LittleEndian.PutUint32(buf[12:], math.Float32bits(raw.UserY))
+	buf[16] = raw.Width
+	buf[17] = raw.Height
+	b = append(b, buf[:]...)
+	b, err := raw.HardwareVersion.AppendBinary(b)
+	if err != nil {
+		return b, err
+	}
+	b, err = raw.Firmware.AppendBinary(b)
+	if err != nil {
+		return b, err
+	}
+	return append(b, 0, 0, 0, 0), nil // reserved
+}
+
+// UnmarshalBinary implements encoding.BinaryUnmarshaler.
+func (raw *RawTileDevice) UnmarshalBinary(data []byte) error {
+	if len(data) < RawTileDeviceLength {
+		return io.ErrUnexpectedEOF
+	}
+	raw.AccelMeasX = int16(binary.LittleEndian.Uint16(data[0:]))
+	raw.AccelMeasY = int16(binary.LittleEndian.Uint16(data[2:]))
+	raw.AccelMeasZ = int16(binary.LittleEndian.Uint16(data[4:]))
+	raw.UserX = math.Float32frombits(binary.LittleEndian.Uint32(data[8:]))
+	raw.UserY = math.Float32frombits(binary.LittleEndian.Uint32(data[12:]))
+	raw.Width = data[16]
+	raw.Height = data[17]
+	if err := raw.HardwareVersion.UnmarshalBinary(data[19:]); err != nil {
+		return err
+	}
+	return raw.Firmware.UnmarshalBinary(data[31:])
+}
+
 // Tile defines a single tile inside a TileDevice
 type Tile struct {
 	UserX    float32
@@ -1,15 +1,37 @@
 package tile
 
 import (
-	"bytes"
 	"context"
-	"encoding/binary"
 	"errors"
+	"fmt"
+	"io"
 
 	"go.yhsif.com/lifxlan"
 	"go.yhsif.com/lifxlan/light"
 )
 
+// ErrNoTiles is the error returned by Wrap when the device responds with an
+// empty device chain.
+var ErrNoTiles = errors.New("lifxlan/tile.Wrap: no tiles found")
+
+// DeviceChainError is the error returned by Wrap when the device responds with
+// a device chain out of the bounds of the TileDevices array.
+type DeviceChainError struct {
+	StartIndex uint8
+	TotalCount uint8
+}
+
+var _ error = (*DeviceChainError)(nil)
+
+func (e *DeviceChainError) Error() string {
+	return fmt.Sprintf(
+		"lifxlan/tile.Wrap: invalid device chain: start index %d, total count %d, max %d tiles",
+		e.StartIndex,
+		e.TotalCount,
+		len(RawStateDeviceChainPayload{}.TileDevices),
+	)
+}
+
 // Wrap tries to wrap a lifxlan.Device into a tile device.
 //
 // When force is false and d is already a tile device,
@@ -38,69 +60,39 @@ func Wrap(ctx context.Context, d lifxlan.Device, force bool) (Device, error) {
 		return nil, err
 	}
 
-	conn, err := d.Dial()
-	if err != nil {
-		return nil, err
-	}
-	defer conn.Close()
-
-	if ctx.Err() != nil {
-		return nil, ctx.Err()
-	}
-
-	const msg = GetDeviceChain
-
-	seq, err := d.Send(
+	var raw RawStateDeviceChainPayload
+	if err := lifxlan.Request(
 		ctx,
-		conn,
-		0, // flags
-		msg,
+		d,
+		nil, // conn
+		GetDeviceChain,
 		nil, // payload
-	)
-	if err != nil {
+		StateDeviceChain,
+		&raw,
+	); err != nil {
 		return nil, err
 	}
-
-	for {
-		resp, err := lifxlan.ReadNextResponse(ctx, conn)
-		if err != nil {
-			return nil, err
-		}
-		if resp.Sequence != seq || resp.Source != d.Source() {
-			continue
+	if raw.TotalCount == 0 {
+		return nil, ErrNoTiles
+	}
+	if int(raw.StartIndex)+int(raw.TotalCount) > len(raw.TileDevices) {
+		return nil, &DeviceChainError{
+			StartIndex: raw.StartIndex,
+			TotalCount: raw.TotalCount,
 		}
+	}
 
-		switch resp.Message {
-		case StateDeviceChain:
-			var raw RawStateDeviceChainPayload
-			r := bytes.NewReader(resp.Payload)
-			if err := binary.Read(r, binary.LittleEndian, &raw); err != nil {
-				return nil, err
-			}
-			if raw.TotalCount == 0 {
-				return nil, errors.New("lifxlan/tile.Wrap: no tiles found")
-			}
-			*d.HardwareVersion() = raw.TileDevices[int(raw.StartIndex)].HardwareVersion
-			td := &device{
-				Device:     ld,
-				startIndex: raw.StartIndex,
-				tiles:      make([]*Tile, raw.TotalCount),
-			}
-			for i := range td.tiles {
-				td.tiles[i] = ParseTile(&raw.TileDevices[int(raw.StartIndex)+i])
-			}
-			td.parseBoard()
-			return td, nil
-
-		case lifxlan.StateUnhandled:
-			var raw lifxlan.RawStateUnhandledPayload
-			r := bytes.NewReader(resp.Payload)
-			if err := binary.Read(r, binary.LittleEndian, &raw); err != nil {
-				return nil, err
-			}
-			return nil, raw
-		}
+	*d.HardwareVersion() = raw.TileDevices[int(raw.StartIndex)].HardwareVersion
+	td := &device{
+		Device:     ld,
+		startIndex: raw.StartIndex,
+		tiles:      make([]*Tile, raw.TotalCount),
 	}
+	for i := range td.tiles {
+		td.tiles[i] = ParseTile(&raw.TileDevices[int(raw.StartIndex)+i])
+	}
+	td.parseBoard()
+	return td, nil
 }
 
 // RawStateDeviceChainPayload defines the struct to be used for encoding and
@@ -112,3 +104,39 @@ type RawStateDeviceChainPayload struct {
 	TileDevices [16]RawTileDevice
 	TotalCount  uint8
 }
+
+// RawStateDeviceChainPayloadLength is the length of the encoded
+// RawStateDeviceChainPayload.
+const RawStateDeviceChainPayloadLength = 2 + 16*RawTileDeviceLength
+
+// MarshalBinary implements encoding.BinaryMarshaler.
+func (p *RawStateDeviceChainPayload) MarshalBinary() ([]byte, error) {
+	return p.AppendBinary(make([]byte, 0, RawStateDeviceChainPayloadLength))
+}
+
+// AppendBinary implements lifxlan.BinaryAppender.
+func (p *RawStateDeviceChainPayload) AppendBinary(b []byte) ([]byte, error) {
+	b = append(b, p.StartIndex)
+	for i := range p.TileDevices {
+		var err error
+		if b, err = p.TileDevices[i].AppendBinary(b); err != nil {
+			return b, err
+		}
+	}
+	return append(b, p.TotalCount), nil
+}
+
+// UnmarshalBinary implements encoding.BinaryUnmarshaler.
+func (p *RawStateDeviceChainPayload) UnmarshalBinary(data []byte) error {
+	if len(data) < RawStateDeviceChainPayloadLength {
+		return io.ErrUnexpectedEOF
+	}
+	p.StartIndex = data[0]
+	for i := range p.TileDevices {
+		if err := p.TileDevices[i].UnmarshalBinary(data[1+i*RawTileDeviceLength:]); err != nil {
+			return err
+		}
+	}
+	p.TotalCount = data[RawStateDeviceChainPayloadLength-1]
+	return nil
+}
@@ -2,6 +2,7 @@ package tile_test
 
 import (
 	"context"
+	"errors"
 	"reflect"
 	"testing"
 	"time"
@@ -96,8 +97,54 @@ func TestWrap(t *testing.T) {
 			ctx, cancel := context.WithTimeout(context.Background(), timeout)
 			defer cancel()
 
-			if _, err := tile.Wrap(ctx, device, false); err == nil {
-				t.Error("Expected error when no tiles in device, got nil")
+			if _, err := tile.Wrap(ctx, device, false); !errors.Is(err, tile.ErrNoTiles) {
+				t.Errorf("Expected ErrNoTiles when no tiles in device, got %v", err)
+			}
+		},
+	)
+
+	t.Run(
+		"InvalidChain",
+		func(t *testing.T) {
+			for _, c := range []struct {
+				label      string
+				startIndex uint8
+				totalCount uint8
+			}{
+				{
+					label:      "StartIndex",
+					startIndex: 16,
+					totalCount: 1,
+				},
+				{
+					label:      "TotalCount",
+					startIndex: 0,
+					totalCount: 17,
+				},
+				{
+					label:      "Sum",
+					startIndex: 10,
+					totalCount: 7,
+				},
+			} {
+				t.Run(c.label, func(t *testing.T) {
+					service.RawStateDeviceChainPayload = &tile.RawStateDeviceChainPayload{
+						StartIndex: c.startIndex,
+						TotalCount: c.totalCount,
+					}
+
+					ctx, cancel := context.WithTimeout(context.Background(), timeout)
+					defer cancel()
+
+					_, err := tile.Wrap(ctx, device, false)
+					var chainErr *tile.DeviceChainError
+					if !errors.As(err, &chainErr) {
+						t.Fatalf("Expected *DeviceChainError, got %v", err)
+					}
+					if chainErr.StartIndex != c.startIndex || chainErr.TotalCount != c.totalCount {
+						t.Errorf("Unexpected error %+v", *chainErr)
+					}
+				})
 			}
 		},
 	)
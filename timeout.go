@@ -11,8 +11,9 @@ import (
 // continue reading,
 // instead of return upon timeout.
 //
-// It's intentionally defined as variable instead of constant,
-// so the user could adjust it if needed.
+// It's the default value of Config.ReadTimeout.
+// Changing it while there are API calls running is a data race,
+// use Config instead to adjust it for some API calls.
 var UDPReadTimeout = time.Millisecond * 100
 
 // GetReadDeadline returns a value can be used in net.Conn.SetReadDeadline from
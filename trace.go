@@ -0,0 +1,151 @@
+package lifxlan
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+)
+
+// DropReason is the reason a received response was skipped.
+type DropReason int
+
+// DropReason values.
+const (
+	// The response was sent to a different source.
+	DropWrongSource DropReason = iota + 1
+
+	// The response doesn't match the sequence of any of the sent messages,
+	// or the ack for the sequence was already received.
+	DropWrongSequence
+
+	// The response is not of the expected message type.
+	DropWrongType
+
+	// The StateService response is for a service type not supported by this
+	// package.
+	DropUnknownService
+
+	// The response is from a different target than the one looked for.
+	DropWrongTarget
+)
+
+func (r DropReason) String() string {
+	switch r {
+	default:
+		return fmt.Sprintf("<UNKNOWN> (%d)", int(r))
+	case DropWrongSource:
+		return "wrong source"
+	case DropWrongSequence:
+		return "wrong sequence"
+	case DropWrongType:
+		return "wrong type"
+	case DropUnknownService:
+		return "unknown service"
+	case DropWrongTarget:
+		return "wrong target"
+	}
+}
+
+// Tracer observes the messages sent and received by this package.
+//
+// The Tracer is resolved in the following order,
+// the first non-nil one wins:
+//
+// 1. The Tracer set on the device via Device.SetTracer,
+// for device APIs and Send.
+//
+// 2. The Tracer attached to the context via WithTracer.
+//
+// 3. The global Tracer set via SetTracer.
+//
+// Tracer functions are called synchronously from the API calls,
+// so they should return quickly,
+// and they must be safe for concurrent use.
+// The args must not be modified, or retained after the functions return.
+//
+// NopTracer can be embedded to only implement some of the functions.
+type Tracer interface {
+	// OnSend is called by Send and Discover after a message is written.
+	OnSend(target Target, header RawHeader, payload []byte)
+
+	// OnReceive is called by ReadNextResponse and Discover after a response is
+	// read and parsed.
+	OnReceive(resp *Response)
+
+	// OnDrop is called when a received response is skipped because it doesn't
+	// match what the API call is waiting for.
+	OnDrop(reason DropReason, resp *Response)
+}
+
+// NopTracer is a Tracer that does nothing.
+type NopTracer struct{}
+
+var _ Tracer = NopTracer{}
+
+// OnSend implements Tracer.
+func (NopTracer) OnSend(Target, RawHeader, []byte) {}
+
+// OnReceive implements Tracer.
+func (NopTracer) OnReceive(*Response) {}
+
+// OnDrop implements Tracer.
+func (NopTracer) OnDrop(DropReason, *Response) {}
+
+// tracerHolder wraps Tracer to be stored in atomic.Value.
+type tracerHolder struct {
+	tracer Tracer
+}
+
+var globalTracer atomic.Value // tracerHolder
+
+// SetTracer sets the global Tracer.
+//
+// nil clears the global Tracer.
+func SetTracer(tracer Tracer) {
+	globalTracer.Store(tracerHolder{tracer: tracer})
+}
+
+type tracerKey struct{}
+
+// WithTracer returns a copy of ctx with tracer attached.
+//
+// If tracer is nil, ctx is returned as-is.
+func WithTracer(ctx context.Context, tracer Tracer) context.Context {
+	if tracer == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, tracerKey{}, tracer)
+}
+
+// TracerFromContext returns the Tracer attached to ctx,
+// or the global Tracer if ctx doesn't have one.
+//
+// It's guaranteed to be non-nil, NopTracer is returned when there's no Tracer.
+func TracerFromContext(ctx context.Context) Tracer {
+	if tracer, ok := ctx.Value(tracerKey{}).(Tracer); ok {
+		return tracer
+	}
+	if holder, ok := globalTracer.Load().(tracerHolder); ok && holder.tracer != nil {
+		return holder.tracer
+	}
+	return NopTracer{}
+}
+
+// tracerFor returns the Tracer to be used by API calls on d.
+func tracerFor(ctx context.Context, d Device) Tracer {
+	if tracer := d.Tracer(); tracer != nil {
+		return tracer
+	}
+	return TracerFromContext(ctx)
+}
+
+func (d *device) Tracer() Tracer {
+	if holder, ok := d.tracer.Load().(tracerHolder); ok {
+		return holder.tracer
+	}
+	return nil
+}
+
+func (d *device) SetTracer(tracer Tracer) {
+	d.tracer.Store(tracerHolder{tracer: tracer})
+}
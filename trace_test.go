@@ -0,0 +1,172 @@
+package lifxlan_test
+
+import (
+	"context"
+	"io"
+	"net"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"go.yhsif.com/lifxlan"
+	"go.yhsif.com/lifxlan/mock"
+)
+
+type recordTracer struct {
+	lock     sync.Mutex
+	sent     []lifxlan.MessageType
+	received []lifxlan.MessageType
+	dropped  []lifxlan.DropReason
+}
+
+func (r *recordTracer) OnSend(_ lifxlan.Target, header lifxlan.RawHeader, _ []byte) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.sent = append(r.sent, header.Type)
+}
+
+func (r *recordTracer) OnReceive(resp *lifxlan.Response) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.received = append(r.received, resp.Message)
+}
+
+func (r *recordTracer) OnDrop(reason lifxlan.DropReason, _ *lifxlan.Response) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.dropped = append(r.dropped, reason)
+}
+
+// queueConn is a net.Conn that returns the queued messages on reads.
+type queueConn struct {
+	net.Conn
+
+	msgs [][]byte
+}
+
+func (c *queueConn) Read(b []byte) (int, error) {
+	if len(c.msgs) == 0 {
+		return 0, io.EOF
+	}
+	msg := c.msgs[0]
+	c.msgs = c.msgs[1:]
+	return copy(b, msg), nil
+}
+
+func (c *queueConn) SetReadDeadline(time.Time) error {
+	return nil
+}
+
+func TestTracer(t *testing.T) {
+	t.Run(
+		"Device",
+		func(t *testing.T) {
+			if testing.Short() {
+				t.Skip("skipping test in short mode.")
+			}
+
+			const timeout = time.Millisecond * 200
+
+			service, device := mock.StartService(t)
+			service.RawStatePowerPayload = &lifxlan.RawStatePowerPayload{
+				Level: lifxlan.PowerOn,
+			}
+			tracer := new(recordTracer)
+			device.SetTracer(tracer)
+
+			ctx, cancel := context.WithTimeout(context.Background(), timeout)
+			defer cancel()
+			if _, err := device.GetPower(ctx, nil); err != nil {
+				t.Fatal(err)
+			}
+
+			expectedSent := []lifxlan.MessageType{lifxlan.GetPower}
+			if !reflect.DeepEqual(tracer.sent, expectedSent) {
+				t.Errorf("Sent expected %v, got %v", expectedSent, tracer.sent)
+			}
+			expectedReceived := []lifxlan.MessageType{lifxlan.StatePower}
+			if !reflect.DeepEqual(tracer.received, expectedReceived) {
+				t.Errorf("Received expected %v, got %v", expectedReceived, tracer.received)
+			}
+			if len(tracer.dropped) != 0 {
+				t.Errorf("Dropped expected empty, got %v", tracer.dropped)
+			}
+		},
+	)
+
+	t.Run(
+		"WaitForAcks",
+		func(t *testing.T) {
+			const source = 1234
+			const seq = 12
+
+			makeMsg := func(source uint32, seq uint8, message lifxlan.MessageType) []byte {
+				t.Helper()
+				msg, err := lifxlan.GenerateMessage(
+					lifxlan.NotTagged,
+					source,
+					lifxlan.AllDevices,
+					0, // flags
+					seq,
+					message,
+					nil, // payload
+				)
+				if err != nil {
+					t.Fatal(err)
+				}
+				return msg
+			}
+			conn := &queueConn{
+				msgs: [][]byte{
+					makeMsg(source+1, seq, lifxlan.Acknowledgement),
+					makeMsg(source, seq, lifxlan.StateLabel),
+					makeMsg(source, seq+1, lifxlan.Acknowledgement),
+					makeMsg(source, seq, lifxlan.Acknowledgement),
+				},
+			}
+
+			tracer := new(recordTracer)
+			ctx := lifxlan.WithTracer(context.Background(), tracer)
+			if err := lifxlan.WaitForAcks(ctx, conn, source, seq); err != nil {
+				t.Fatal(err)
+			}
+
+			expectedReceived := []lifxlan.MessageType{
+				lifxlan.Acknowledgement,
+				lifxlan.StateLabel,
+				lifxlan.Acknowledgement,
+				lifxlan.Acknowledgement,
+			}
+			if !reflect.DeepEqual(tracer.received, expectedReceived) {
+				t.Errorf("Received expected %v, got %v", expectedReceived, tracer.received)
+			}
+			expectedDropped := []lifxlan.DropReason{
+				lifxlan.DropWrongSource,
+				lifxlan.DropWrongType,
+				lifxlan.DropWrongSequence,
+			}
+			if !reflect.DeepEqual(tracer.dropped, expectedDropped) {
+				t.Errorf("Dropped expected %v, got %v", expectedDropped, tracer.dropped)
+			}
+		},
+	)
+
+	t.Run(
+		"Global",
+		func(t *testing.T) {
+			tracer := new(recordTracer)
+			lifxlan.SetTracer(tracer)
+			defer lifxlan.SetTracer(nil)
+
+			if got := lifxlan.TracerFromContext(context.Background()); got != tracer {
+				t.Errorf("Expected global tracer %v, got %v", tracer, got)
+			}
+			other := new(recordTracer)
+			ctx := lifxlan.WithTracer(context.Background(), other)
+			if got := lifxlan.TracerFromContext(ctx); got != other {
+				t.Errorf("Expected context tracer %v, got %v", other, got)
+			}
+		},
+	)
+}
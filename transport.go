@@ -0,0 +1,52 @@
+package lifxlan
+
+import (
+	"net"
+)
+
+// Transport defines how connections to devices are made.
+//
+// *net.Dialer, *Client, and *ConnPool all implement Transport.
+type Transport interface {
+	// Dial connects to the address on the named network,
+	// with the same semantics as net.Dial.
+	Dial(network, address string) (net.Conn, error)
+}
+
+// TransportFunc is an adapter to use a function as Transport.
+type TransportFunc func(network, address string) (net.Conn, error)
+
+var _ Transport = TransportFunc(nil)
+
+// Dial calls f(network, address).
+func (f TransportFunc) Dial(network, address string) (net.Conn, error) {
+	return f(network, address)
+}
+
+var (
+	_ Transport = (*net.Dialer)(nil)
+	_ Transport = (*Client)(nil)
+	_ Transport = (*ConnPool)(nil)
+)
+
+// DefaultTransport is the Transport used by devices created without
+// WithTransport option, which is the same as net.Dial.
+var DefaultTransport Transport = new(net.Dialer)
+
+// DeviceOption defines the options to be used with NewDevice.
+type DeviceOption func(d *device)
+
+// WithTransport sets the Transport used by Device.Dial.
+//
+// It can be used to bind a specific local address (via net.Dialer.LocalAddr),
+// use a net.Dialer with Control hooks,
+// share a single socket with a Client,
+// reuse connections with a ConnPool,
+// or inject an in-memory transport for tests.
+//
+// nil Transport means DefaultTransport.
+func WithTransport(transport Transport) DeviceOption {
+	return func(d *device) {
+		d.transport = transport
+	}
+}
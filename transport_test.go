@@ -0,0 +1,94 @@
+package lifxlan_test
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"go.yhsif.com/lifxlan"
+	"go.yhsif.com/lifxlan/mock"
+)
+
+func TestTransport(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping test in short mode.")
+	}
+
+	const timeout = time.Millisecond * 200
+
+	service, mockDevice := mock.StartService(t)
+	service.RawStatePowerPayload = &lifxlan.RawStatePowerPayload{
+		Level: lifxlan.PowerOn,
+	}
+
+	t.Run(
+		"Func",
+		func(t *testing.T) {
+			var dialed []string
+			device := lifxlan.NewDevice(
+				mockDevice.Addr().String(),
+				lifxlan.ServiceUDP,
+				mockDevice.Target(),
+				lifxlan.WithTransport(lifxlan.TransportFunc(
+					func(network, address string) (net.Conn, error) {
+						dialed = append(dialed, network+"://"+address)
+						return net.Dial(network, address)
+					},
+				)),
+			)
+
+			ctx, cancel := context.WithTimeout(context.Background(), timeout)
+			defer cancel()
+
+			if _, err := device.GetPower(ctx, nil); err != nil {
+				t.Fatal(err)
+			}
+			expected := "udp://" + mockDevice.Addr().String()
+			if len(dialed) != 1 || dialed[0] != expected {
+				t.Errorf("Expected dialed [%s], got %v", expected, dialed)
+			}
+		},
+	)
+
+	t.Run(
+		"Client",
+		func(t *testing.T) {
+			device := lifxlan.NewDevice(
+				mockDevice.Addr().String(),
+				lifxlan.ServiceUDP,
+				mockDevice.Target(),
+				lifxlan.WithTransport(newClient(t)),
+			)
+
+			ctx, cancel := context.WithTimeout(context.Background(), timeout)
+			defer cancel()
+
+			if _, err := device.GetPower(ctx, nil); err != nil {
+				t.Fatal(err)
+			}
+		},
+	)
+
+	t.Run(
+		"Error",
+		func(t *testing.T) {
+			expected := errors.New("foo")
+			device := lifxlan.NewDevice(
+				mockDevice.Addr().String(),
+				lifxlan.ServiceUDP,
+				mockDevice.Target(),
+				lifxlan.WithTransport(lifxlan.TransportFunc(
+					func(network, address string) (net.Conn, error) {
+						return nil, expected
+					},
+				)),
+			)
+
+			if _, err := device.Dial(); err != expected {
+				t.Errorf("Expected error %v, got %v", expected, err)
+			}
+		},
+	)
+}
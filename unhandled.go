@@ -1,7 +1,9 @@
 package lifxlan
 
 import (
+	"encoding/binary"
 	"fmt"
+	"io"
 )
 
 // RawStateUnhandledPayload defines the struct to be used for encoding and decoding.
@@ -11,6 +13,27 @@ type RawStateUnhandledPayload struct {
 	UnhandledType MessageType
 }
 
+// MarshalBinary implements encoding.BinaryMarshaler.
+func (p *RawStateUnhandledPayload) MarshalBinary() ([]byte, error) {
+	return p.AppendBinary(make([]byte, 0, 2))
+}
+
+// AppendBinary implements BinaryAppender.
+func (p *RawStateUnhandledPayload) AppendBinary(b []byte) ([]byte, error) {
+	b, buf := grow(b, 2)
+	binary.LittleEndian.PutUint16(buf, uint16(p.UnhandledType))
+	return b, nil
+}
+
+// UnmarshalBinary implements encoding.BinaryUnmarshaler.
+func (p *RawStateUnhandledPayload) UnmarshalBinary(data []byte) error {
+	if len(data) < 2 {
+		return io.ErrUnexpectedEOF
+	}
+	p.UnhandledType = MessageType(binary.LittleEndian.Uint16(data))
+	return nil
+}
+
 func (p RawStateUnhandledPayload) Error() string {
 	return fmt.Sprintf(
 		"lifxlan: unhandled message: %v",
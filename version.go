@@ -1,10 +1,10 @@
 package lifxlan
 
 import (
-	"bytes"
 	"context"
 	"encoding/binary"
 	"fmt"
+	"io"
 	"net"
 	"strings"
 )
@@ -21,6 +21,21 @@ type RawStateVersionPayload struct {
 	Version HardwareVersion
 }
 
+// MarshalBinary implements encoding.BinaryMarshaler.
+func (p *RawStateVersionPayload) MarshalBinary() ([]byte, error) {
+	return p.AppendBinary(make([]byte, 0, HardwareVersionLength))
+}
+
+// AppendBinary implements BinaryAppender.
+func (p *RawStateVersionPayload) AppendBinary(b []byte) ([]byte, error) {
+	return p.Version.AppendBinary(b)
+}
+
+// UnmarshalBinary implements encoding.BinaryUnmarshaler.
+func (p *RawStateVersionPayload) UnmarshalBinary(data []byte) error {
+	return p.Version.UnmarshalBinary(data)
+}
+
 // ProductMapKey generates key for ProductMap based on vendor and product ids.
 func ProductMapKey(vendor, product uint32) uint64 {
 	return uint64(vendor)<<32 + uint64(product)
@@ -35,6 +50,34 @@ type HardwareVersion struct {
 	HardwareVersion uint32
 }
 
+// HardwareVersionLength is the length of the encoded HardwareVersion.
+const HardwareVersionLength = 12
+
+// MarshalBinary implements encoding.BinaryMarshaler.
+func (raw *HardwareVersion) MarshalBinary() ([]byte, error) {
+	return raw.AppendBinary(make([]byte, 0, HardwareVersionLength))
+}
+
+// AppendBinary implements BinaryAppender.
+func (raw *HardwareVersion) AppendBinary(b []byte) ([]byte, error) {
+	b, buf := grow(b, HardwareVersionLength)
+	binary.LittleEndian.PutUint32(buf[0:], raw.VendorID)
+	binary.LittleEndian.PutUint32(buf[4:], raw.ProductID)
+	binary.LittleEndian.PutUint32(buf[8:], raw.HardwareVersion)
+	return b, nil
+}
+
+// UnmarshalBinary implements encoding.BinaryUnmarshaler.
+func (raw *HardwareVersion) UnmarshalBinary(data []byte) error {
+	if len(data) < HardwareVersionLength {
+		return io.ErrUnexpectedEOF
+	}
+	raw.VendorID = binary.LittleEndian.Uint32(data[0:])
+	raw.ProductID = binary.LittleEndian.Uint32(data[4:])
+	raw.HardwareVersion = binary.LittleEndian.Uint32(data[8:])
+	return nil
+}
+
 // ProductMapKey generates key for ProductMap.
 func (raw HardwareVersion) ProductMapKey() uint64 {
 	return ProductMapKey(raw.VendorID, raw.ProductID)
@@ -73,53 +116,18 @@ func (d *device) HardwareVersion() *HardwareVersion {
 }
 
 func (d *device) GetHardwareVersion(ctx context.Context, conn net.Conn) error {
-	if ctx.Err() != nil {
-		return ctx.Err()
-	}
-
-	if conn == nil {
-		newConn, err := d.Dial()
-		if err != nil {
-			return err
-		}
-		defer newConn.Close()
-		conn = newConn
-
-		if ctx.Err() != nil {
-			return ctx.Err()
-		}
-	}
-
-	seq, err := d.Send(
+	var raw RawStateVersionPayload
+	if err := Request(
 		ctx,
+		d,
 		conn,
-		0, // flags
 		GetVersion,
 		nil, // payload
-	)
-	if err != nil {
+		StateVersion,
+		&raw,
+	); err != nil {
 		return err
 	}
-
-	for {
-		resp, err := ReadNextResponse(ctx, conn)
-		if err != nil {
-			return err
-		}
-		if resp.Sequence != seq || resp.Source != d.Source() {
-			continue
-		}
-		if resp.Message != StateVersion {
-			continue
-		}
-
-		var raw RawStateVersionPayload
-		r := bytes.NewReader(resp.Payload)
-		if err := binary.Read(r, binary.LittleEndian, &raw); err != nil {
-			return err
-		}
-
-		d.version = raw.Version
-		return nil
-	}
+	d.version = raw.Version
+	return nil
 }
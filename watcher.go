@@ -0,0 +1,354 @@
+package lifxlan
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Default values for WatcherOptions.
+const (
+	DefaultWatchDiscoverInterval = time.Minute
+	DefaultWatchDiscoverTimeout  = time.Second
+	DefaultWatchPingInterval     = time.Second * 10
+	DefaultWatchPingTimeout      = time.Second
+	DefaultWatchGracePeriod      = time.Second * 30
+)
+
+// WatchEventType is the type of a WatchEvent.
+type WatchEventType int
+
+// WatchEventType values.
+const (
+	// A new device is found.
+	Joined WatchEventType = iota + 1
+
+	// A known device is not seen for the grace period.
+	Left
+
+	// A known device is found at a different address.
+	AddressChanged
+)
+
+func (t WatchEventType) String() string {
+	switch t {
+	default:
+		return fmt.Sprintf("<UNKNOWN> (%d)", int(t))
+	case Joined:
+		return "joined"
+	case Left:
+		return "left"
+	case AddressChanged:
+		return "address changed"
+	}
+}
+
+// WatchEvent is the event emitted by Watcher.
+type WatchEvent struct {
+	Type WatchEventType
+
+	// The target of the device.
+	Target Target
+
+	// The device.
+	//
+	// For AddressChanged it's a new Device at the new address,
+	// for Left it's the last known Device.
+	Device Device
+
+	// The previous address of the device,
+	// only set for AddressChanged.
+	OldAddr net.Addr
+}
+
+// WatcherOptions defines the options used by Watcher.
+//
+// The zero value uses the defaults for all fields.
+type WatcherOptions struct {
+	// The options used by every discovery.
+	//
+	// QuietPeriod can be used to end the discoveries before DiscoverTimeout.
+	Discover DiscoverOptions
+
+	// How often to rediscover the devices.
+	//
+	// Values <= 0 mean DefaultWatchDiscoverInterval.
+	DiscoverInterval time.Duration
+
+	// How long every discovery lasts.
+	//
+	// Values <= 0 mean DefaultWatchDiscoverTimeout.
+	DiscoverTimeout time.Duration
+
+	// How often to ping the known devices with Echo.
+	//
+	// Values <= 0 mean DefaultWatchPingInterval.
+	PingInterval time.Duration
+
+	// The timeout of every ping.
+	//
+	// Values <= 0 mean DefaultWatchPingTimeout.
+	PingTimeout time.Duration
+
+	// A known device is declared gone (Left) when it's not seen,
+	// by either discoveries or pings, for GracePeriod.
+	//
+	// Values <= 0 mean DefaultWatchGracePeriod.
+	GracePeriod time.Duration
+}
+
+func (opts WatcherOptions) discoverInterval() time.Duration {
+	if opts.DiscoverInterval > 0 {
+		return opts.DiscoverInterval
+	}
+	return DefaultWatchDiscoverInterval
+}
+
+func (opts WatcherOptions) discoverTimeout() time.Duration {
+	if opts.DiscoverTimeout > 0 {
+		return opts.DiscoverTimeout
+	}
+	return DefaultWatchDiscoverTimeout
+}
+
+func (opts WatcherOptions) pingInterval() time.Duration {
+	if opts.PingInterval > 0 {
+		return opts.PingInterval
+	}
+	return DefaultWatchPingInterval
+}
+
+func (opts WatcherOptions) pingTimeout() time.Duration {
+	if opts.PingTimeout > 0 {
+		return opts.PingTimeout
+	}
+	return DefaultWatchPingTimeout
+}
+
+func (opts WatcherOptions) gracePeriod() time.Duration {
+	if opts.GracePeriod > 0 {
+		return opts.GracePeriod
+	}
+	return DefaultWatchGracePeriod
+}
+
+// Watcher keeps track of the devices in the lan continuously,
+// by periodically rediscovering them and pinging the known ones,
+// and emits WatchEvents keyed by their targets.
+type Watcher struct {
+	opts WatcherOptions
+
+	lock    sync.Mutex
+	devices map[Target]*watchedDevice
+}
+
+type watchedDevice struct {
+	device   Device
+	lastSeen time.Time
+}
+
+// NewWatcher creates a new Watcher.
+func NewWatcher(opts WatcherOptions) *Watcher {
+	return &Watcher{
+		opts:    opts,
+		devices: make(map[Target]*watchedDevice),
+	}
+}
+
+// Devices returns the currently known devices, sorted by their targets.
+func (w *Watcher) Devices() []Device {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	devices := make([]Device, 0, len(w.devices))
+	for _, wd := range w.devices {
+		devices = append(devices, wd.device)
+	}
+	sort.Slice(devices, func(i, j int) bool {
+		return devices[i].Target() < devices[j].Target()
+	})
+	return devices
+}
+
+// Run runs the Watcher until ctx is done,
+// writing the events into events channel.
+//
+// It discovers the devices immediately, then every DiscoverInterval,
+// and pings the known devices every PingInterval.
+// Failed discoveries are logged via the Config from ctx,
+// and retried on the next DiscoverInterval.
+//
+// It's the caller's responsibility to read from channel timely,
+// as the Watcher is blocked while writing.
+// The function is guaranteed to close the channel upon returning,
+// so the caller could just range over the channel for reading, e.g.
+//
+//     events := make(chan lifxlan.WatchEvent)
+//     go func() {
+//       if err := watcher.Run(ctx, events); err != nil {
+//         if err != context.Canceled {
+//           // handle error
+//         }
+//       }
+//     }()
+//     for event := range events {
+//       // Do something with event
+//     }
+//
+// The known devices are kept between runs,
+// but Run shall not be called concurrently on the same Watcher.
+//
+// It only returns when ctx is done, in which case ctx.Err() is returned.
+func (w *Watcher) Run(ctx context.Context, events chan WatchEvent) error {
+	defer close(events)
+
+	discoverTicker := time.NewTicker(w.opts.discoverInterval())
+	defer discoverTicker.Stop()
+	pingTicker := time.NewTicker(w.opts.pingInterval())
+	defer pingTicker.Stop()
+
+	discover := func() error {
+		if err := w.discover(ctx, events); err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			ConfigFromContext(ctx).Logf(
+				"lifxlan.Watcher: discovery failed, retrying in %v: %v",
+				w.opts.discoverInterval(),
+				err,
+			)
+		}
+		return nil
+	}
+
+	if err := discover(); err != nil {
+		return err
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-discoverTicker.C:
+			if err := discover(); err != nil {
+				return err
+			}
+		case <-pingTicker.C:
+			w.ping(ctx)
+		}
+		if err := w.expire(ctx, events); err != nil {
+			return err
+		}
+	}
+}
+
+// emit writes event into events, or returns ctx.Err() if ctx is done first.
+func emit(ctx context.Context, events chan WatchEvent, event WatchEvent) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case events <- event:
+		return nil
+	}
+}
+
+// discover runs a single discovery,
+// and emits Joined and AddressChanged events.
+func (w *Watcher) discover(ctx context.Context, events chan WatchEvent) error {
+	discoverCtx, cancel := context.WithTimeout(ctx, w.opts.discoverTimeout())
+	defer cancel()
+	devices, err := DiscoverAll(discoverCtx, w.opts.Discover)
+	if err != nil {
+		return err
+	}
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	now := time.Now()
+	for _, d := range devices {
+		event := WatchEvent{
+			Target: d.Target(),
+			Device: d,
+		}
+		w.lock.Lock()
+		wd := w.devices[d.Target()]
+		switch {
+		case wd == nil:
+			event.Type = Joined
+			w.devices[d.Target()] = &watchedDevice{
+				device:   d,
+				lastSeen: now,
+			}
+		case wd.device.Addr().String() != d.Addr().String():
+			event.Type = AddressChanged
+			event.OldAddr = wd.device.Addr()
+			wd.device = d
+			wd.lastSeen = now
+		default:
+			wd.lastSeen = now
+		}
+		w.lock.Unlock()
+
+		if event.Type != 0 {
+			if err := emit(ctx, events, event); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// ping pings all the known devices concurrently with Echo.
+func (w *Watcher) ping(ctx context.Context) {
+	var wg sync.WaitGroup
+	for _, d := range w.Devices() {
+		wg.Add(1)
+		go func(d Device) {
+			defer wg.Done()
+
+			pingCtx, cancel := context.WithTimeout(ctx, w.opts.pingTimeout())
+			defer cancel()
+			if err := d.Echo(pingCtx, nil, nil); err != nil {
+				return
+			}
+
+			w.lock.Lock()
+			defer w.lock.Unlock()
+			// The device could have been replaced by AddressChanged.
+			if wd := w.devices[d.Target()]; wd != nil && wd.device == d {
+				wd.lastSeen = time.Now()
+			}
+		}(d)
+	}
+	wg.Wait()
+}
+
+// expire removes the devices not seen for the grace period,
+// and emits Left events.
+func (w *Watcher) expire(ctx context.Context, events chan WatchEvent) error {
+	var left []WatchEvent
+	w.lock.Lock()
+	for target, wd := range w.devices {
+		if time.Since(wd.lastSeen) > w.opts.gracePeriod() {
+			delete(w.devices, target)
+			left = append(left, WatchEvent{
+				Type:   Left,
+				Target: target,
+				Device: wd.device,
+			})
+		}
+	}
+	w.lock.Unlock()
+
+	sort.Slice(left, func(i, j int) bool {
+		return left[i].Target < left[j].Target
+	})
+	for _, event := range left {
+		if err := emit(ctx, events, event); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,180 @@
+package lifxlan_test
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go.yhsif.com/lifxlan"
+	"go.yhsif.com/lifxlan/mock"
+)
+
+// waitWatchEvent waits for the next event from events.
+func waitWatchEvent(t *testing.T, events chan lifxlan.WatchEvent, timeout time.Duration) lifxlan.WatchEvent {
+	t.Helper()
+
+	select {
+	case event := <-events:
+		return event
+	case <-time.After(timeout):
+		t.Fatal("Timed out waiting for event")
+		return lifxlan.WatchEvent{}
+	}
+}
+
+func TestWatcher(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping test in short mode.")
+	}
+
+	const timeout = time.Millisecond * 200
+
+	t.Run(
+		"JoinedLeft",
+		func(t *testing.T) {
+			service, device, _ := startReplyingService(t, func(int) bool {
+				return true
+			})
+			watcher := lifxlan.NewWatcher(lifxlan.WatcherOptions{
+				Discover: lifxlan.DiscoverOptions{
+					BroadcastHosts: []string{device.Addr().String()},
+				},
+				DiscoverInterval: time.Hour,
+				DiscoverTimeout:  timeout / 10,
+				PingInterval:     timeout / 20,
+				PingTimeout:      timeout / 20,
+				GracePeriod:      timeout / 4,
+			})
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			events := make(chan lifxlan.WatchEvent)
+			errChan := make(chan error, 1)
+			go func() {
+				errChan <- watcher.Run(ctx, events)
+			}()
+
+			event := waitWatchEvent(t, events, timeout)
+			if event.Type != lifxlan.Joined || event.Target != mock.Target {
+				t.Errorf("Expected %v of %v, got %+v", lifxlan.Joined, mock.Target, event)
+			}
+			if event.Device.Addr().String() != device.Addr().String() {
+				t.Errorf("Expected addr %v, got %v", device.Addr(), event.Device.Addr())
+			}
+
+			// Pings keep the device beyond the grace period.
+			select {
+			case event := <-events:
+				t.Errorf("Expected no events while the device is online, got %+v", event)
+			case <-time.After(timeout / 2):
+			}
+			if devices := watcher.Devices(); len(devices) != 1 {
+				t.Errorf("Expected 1 device, got %v", devices)
+			}
+
+			service.Stop()
+			event = waitWatchEvent(t, events, timeout)
+			if event.Type != lifxlan.Left || event.Target != mock.Target {
+				t.Errorf("Expected %v of %v, got %+v", lifxlan.Left, mock.Target, event)
+			}
+			if devices := watcher.Devices(); len(devices) != 0 {
+				t.Errorf("Expected 0 devices, got %v", devices)
+			}
+
+			cancel()
+			if err := <-errChan; err != context.Canceled {
+				t.Errorf("Expected %v, got %v", context.Canceled, err)
+			}
+			if _, ok := <-events; ok {
+				t.Error("Expected events to be closed")
+			}
+		},
+	)
+
+	t.Run(
+		"AddressChanged",
+		func(t *testing.T) {
+			oldService, oldDevice, _ := startReplyingService(t, func(int) bool {
+				return true
+			})
+			var moved int32
+			_, newDevice, _ := startReplyingService(t, func(int) bool {
+				return atomic.LoadInt32(&moved) != 0
+			})
+			watcher := lifxlan.NewWatcher(lifxlan.WatcherOptions{
+				Discover: lifxlan.DiscoverOptions{
+					BroadcastHosts: []string{
+						oldDevice.Addr().String(),
+						newDevice.Addr().String(),
+					},
+				},
+				DiscoverInterval: timeout / 10,
+				DiscoverTimeout:  timeout / 20,
+				PingInterval:     time.Hour,
+				GracePeriod:      time.Hour,
+			})
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			events := make(chan lifxlan.WatchEvent)
+			go watcher.Run(ctx, events)
+
+			event := waitWatchEvent(t, events, timeout)
+			if event.Type != lifxlan.Joined {
+				t.Errorf("Expected %v, got %+v", lifxlan.Joined, event)
+			}
+
+			oldService.Stop()
+			atomic.StoreInt32(&moved, 1)
+			event = waitWatchEvent(t, events, timeout)
+			if event.Type != lifxlan.AddressChanged || event.Target != mock.Target {
+				t.Errorf("Expected %v of %v, got %+v", lifxlan.AddressChanged, mock.Target, event)
+			}
+			if event.OldAddr.String() != oldDevice.Addr().String() {
+				t.Errorf("Expected old addr %v, got %v", oldDevice.Addr(), event.OldAddr)
+			}
+			if event.Device.Addr().String() != newDevice.Addr().String() {
+				t.Errorf("Expected new addr %v, got %v", newDevice.Addr(), event.Device.Addr())
+			}
+		},
+	)
+	t.Run(
+		"DiscoverError",
+		func(t *testing.T) {
+			watcher := lifxlan.NewWatcher(lifxlan.WatcherOptions{
+				Discover: lifxlan.DiscoverOptions{
+					// Fails every discovery.
+					ListenAddr: "invalid address",
+				},
+				DiscoverInterval: timeout / 10,
+				DiscoverTimeout:  timeout / 20,
+				PingInterval:     time.Hour,
+			})
+
+			logger := new(recordLogger)
+			ctx, cancel := context.WithTimeout(
+				lifxlan.ContextWithConfig(context.Background(), &lifxlan.Config{
+					Logger: logger,
+				}),
+				timeout,
+			)
+			defer cancel()
+			events := make(chan lifxlan.WatchEvent)
+			errChan := make(chan error, 1)
+			go func() {
+				errChan <- watcher.Run(ctx, events)
+			}()
+			for event := range events {
+				t.Errorf("Expected no events, got %+v", event)
+			}
+
+			if err := <-errChan; err != context.DeadlineExceeded {
+				t.Errorf("Expected %v, got %v", context.DeadlineExceeded, err)
+			}
+			if logs := logger.get(); len(logs) < 2 {
+				t.Errorf("Expected the failed discoveries to be logged and retried, got %q", logs)
+			}
+		},
+	)
+}